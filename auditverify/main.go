@@ -0,0 +1,62 @@
+// auditverify project main.go
+// Verify the hash chain of audit files written by the master and game servers
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+var expectHead string
+
+func init() {
+	flag.StringVar(&expectHead, "head", "", "Hash the last event should have, catches events cut off the end")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <audit file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || (expectHead != "" && flag.NArg() != 1) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if err := verify(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// verify check a single file and print how far the chain goes
+func verify(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, head, err := audit.Verify(file)
+	if err != nil {
+		return err
+	}
+	if expectHead != "" && head != expectHead {
+		return fmt.Errorf("chain ends at %s after %d events, expected %s", head, count, expectHead)
+	}
+
+	fmt.Printf("%s: OK, %d events, head %s\n", path, count, head)
+	return nil
+}
@@ -0,0 +1,212 @@
+// Package admin serves the local HTTP API operators use to debug a single game node,
+// without going through the master.
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/node"
+	"github.com/zeroZshadow/rose-example/gameserver/room"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/adminapi"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+var log = logging.MustGetLogger("admin")
+
+// roomInfo describes a room to operators
+type roomInfo struct {
+	ID          uint64    `json:"id"`
+	State       int       `json:"state"`
+	Owner       uint64    `json:"owner"`
+	Members     []uint64  `json:"members"`
+	Spectators  []uint64  `json:"spectators"`
+	Created     time.Time `json:"created"`
+	Ticks       uint64    `json:"ticks"`
+	TickRate    int       `json:"tickrate"`
+	LastTick    float64   `json:"lasttickms"`
+	AverageTick float64   `json:"averagetickms"`
+	MaxTick     float64   `json:"maxtickms"`
+	MessagesIn  uint64    `json:"messagesin"`
+	MessagesOut uint64    `json:"messagesout"`
+	InRate      float64   `json:"inrate"`
+	OutRate     float64   `json:"outrate"`
+}
+
+func toRoomInfo(info room.Info) roomInfo {
+	return roomInfo{
+		ID:          uint64(info.ID),
+		State:       info.State,
+		Owner:       uint64(info.Owner),
+		Members:     userIDs(info.Members),
+		Spectators:  userIDs(info.Spectators),
+		Created:     info.Created,
+		Ticks:       info.Ticks,
+		TickRate:    info.TickRate,
+		LastTick:    milliseconds(info.LastTick),
+		AverageTick: milliseconds(info.AverageTick),
+		MaxTick:     milliseconds(info.MaxTick),
+		MessagesIn:  info.MessagesIn,
+		MessagesOut: info.MessagesOut,
+		InRate:      info.InRate,
+		OutRate:     info.OutRate,
+	}
+}
+
+// Serve start the admin API on the given address, the listener runs until the process exits.
+// Without a token only the metrics and health checks are served.
+func Serve(address string, token string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rooms", handleRooms)
+	mux.HandleFunc("/rooms/", handleRoom)
+	mux.HandleFunc("/broadcast", handleBroadcastAll)
+
+	// Metrics and health are for anyone to check
+	public := map[string]http.Handler{
+		"/metrics": promhttp.Handler(),
+		"/healthz": adminapi.HealthHandler(),
+		"/readyz":  adminapi.ReadyHandler(node.Instance.Ready),
+	}
+
+	addr, err := adminapi.Serve(address, token, mux, public)
+	if err != nil {
+		return err
+	}
+
+	log.Noticef("Admin API serving at %s", addr)
+	return nil
+}
+
+// handleRooms GET /rooms
+func handleRooms(w http.ResponseWriter, r *http.Request) {
+	if !adminapi.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	rooms := make([]roomInfo, 0)
+	for _, info := range room.List() {
+		rooms = append(rooms, toRoomInfo(info))
+	}
+
+	adminapi.WriteJSON(w, http.StatusOK, rooms)
+}
+
+// handleRoom GET /rooms/{id}, POST /rooms/{id}/close, POST /rooms/{id}/kick?user=&reason=
+// and POST /rooms/{id}/broadcast?message=
+func handleRoom(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := adminapi.SplitPath(r.URL.Path, "/rooms/")
+	if !ok {
+		adminapi.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+	roomID := rose.RoomID(id)
+
+	if action == "" {
+		if !adminapi.RequireMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		info, ok := room.Inspect(roomID)
+		if !ok {
+			adminapi.WriteError(w, http.StatusNotFound, "room not found")
+			return
+		}
+		adminapi.WriteJSON(w, http.StatusOK, toRoomInfo(info))
+		return
+	}
+
+	if !adminapi.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// What ends up in the audit trail
+	event, target := "room."+action, audit.Room(id)
+	var details map[string]string
+
+	var work func(*room.Room)
+	switch action {
+	case "close":
+		work = func(target *room.Room) {
+			target.Close(pb.RoomCloseReason_Admin)
+		}
+
+	case "kick":
+		userID, err := strconv.ParseUint(r.URL.Query().Get("user"), 10, 64)
+		if err != nil {
+			adminapi.WriteError(w, http.StatusBadRequest, "invalid user")
+			return
+		}
+		reason := r.URL.Query().Get("reason")
+		event, target = "user.kick", audit.User(userID)
+		details = map[string]string{"room": strconv.FormatUint(id, 10), "reason": reason}
+		work = func(target *room.Room) {
+			if !target.Kick(rose.UserID(userID), reason) {
+				log.Warningf("Admin tried to kick user %d who is not in room %d", userID, roomID)
+			}
+		}
+
+	case "broadcast":
+		message := r.URL.Query().Get("message")
+		if message == "" {
+			adminapi.WriteError(w, http.StatusBadRequest, "missing message")
+			return
+		}
+		details = map[string]string{"message": message}
+		work = func(target *room.Room) {
+			target.Announce(message)
+		}
+
+	default:
+		adminapi.WriteError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+
+	// The room picks it up on its next tick
+	if !room.Do(roomID, work) {
+		adminapi.WriteError(w, http.StatusNotFound, "room not found")
+		return
+	}
+
+	log.Noticef("Admin %s room %d from %s", action, roomID, r.RemoteAddr)
+	audit.Record(event, audit.Admin(r.RemoteAddr), target, details)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleBroadcastAll POST /broadcast?message=, send a system message to every room
+func handleBroadcastAll(w http.ResponseWriter, r *http.Request) {
+	if !adminapi.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	message := r.URL.Query().Get("message")
+	if message == "" {
+		adminapi.WriteError(w, http.StatusBadRequest, "missing message")
+		return
+	}
+
+	count := room.DoAll(func(target *room.Room) {
+		target.Announce(message)
+	})
+
+	log.Noticef("Admin broadcast to %d rooms from %s: %s", count, r.RemoteAddr, message)
+	audit.Record("broadcast", audit.Admin(r.RemoteAddr), "", map[string]string{"message": message, "rooms": strconv.Itoa(count)})
+	adminapi.WriteJSON(w, http.StatusAccepted, map[string]int{"rooms": count})
+}
+
+// userIDs convert ids to plain numbers, so they show up as such in JSON
+func userIDs(ids []rose.UserID) []uint64 {
+	result := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, uint64(id))
+	}
+	return result
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
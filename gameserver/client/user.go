@@ -1,31 +1,77 @@
 package client
 
 import (
+	"sync"
+	"time"
+
 	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/metrics"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
 )
 
 type userMessageHandler func(*User, pb.MessageType, []byte) error
 
 // MessageMap Map of messageType handlers
 var MessageMap = make(map[pb.MessageType]userMessageHandler)
-var log = logging.MustGetLogger("global")
+var log = logging.MustGetLogger("client")
+var clientLog = shared.NewLogger("client", nil)
 
 // User is a game user
 type User struct {
 	// Framework things
 	*rose.UserBase
-	Room *rose.RoomFront
+	Profile   shared.PlayerProfile
+	Spectator bool
+
+	// TraceParent the trace of the room request the user joined with
+	TraceParent string
+
+	// room is cleared by rooms closing on their own goroutine, so it's guarded
+	roomLock sync.Mutex
+	room     *rose.RoomFront
+}
+
+// CurrentRoom the room the user is in, nil if they're not in one
+func (user *User) CurrentRoom() *rose.RoomFront {
+	user.roomLock.Lock()
+	defer user.roomLock.Unlock()
+	return user.room
+}
+
+// SetRoom remember the room the user joined
+func (user *User) SetRoom(room *rose.RoomFront) {
+	user.roomLock.Lock()
+	defer user.roomLock.Unlock()
+	user.room = room
+}
+
+// LeaveRoom take the user out of their room, if they're still in one.
+// Safe to call from any goroutine, only the first call leaves.
+func (user *User) LeaveRoom() error {
+	user.roomLock.Lock()
+	room := user.room
+	user.room = nil
+	user.roomLock.Unlock()
+
+	if room == nil {
+		return nil
+	}
+	return rose.RoomLobby.LeaveRoom(room.ID, user)
 }
 
 // HandlePacket sends the received packet data to HandleUserPacket
 func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
 	messageType := pb.MessageType(msgType)
+	user.logger().Debugf("Received %v", messageType)
 
 	// Find handler for message type, run if available
 	if handler, ok := MessageMap[messageType]; ok {
+		metrics.Messages.WithLabelValues("client", messageType.String()).Inc()
+		start := time.Now()
 		err := handler(user, messageType, message)
+		metrics.HandlerDuration.WithLabelValues("client", messageType.String()).Observe(time.Since(start).Seconds())
 		if err != nil {
 			log.Errorf("unmarshaling error: %s\n%v", err, message)
 		}
@@ -33,27 +79,34 @@ func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
 	}
 
 	// Try to handle the message in the user's room instead
-	if user.Room == nil {
+	room := user.CurrentRoom()
+	if room == nil {
 		log.Errorf("Message of type %d arrived from a user without a room.", messageType)
 		return
 	}
 
 	// Queue message in room
-	user.Room.PushMessage(user, msgType, message)
+	room.PushMessage(user, msgType, message)
+}
+
+// logger tags log lines with the user
+func (user *User) logger() *shared.Logger {
+	return clientLog.With(shared.Fields{"user_id": user.ID})
 }
 
 // OnDisconnect removes the user from any connected rooms
 func (user *User) OnDisconnect(err error) {
-	if user.Room != nil {
-		if err := rose.RoomLobby.LeaveRoom(user.Room.ID, user); err != nil {
-			log.Warningf("Unable to exit room %v on disconnect", user.Room.ID)
-		}
-		user.Room = nil
+	metrics.ConnectedClients.Dec()
+
+	if err := user.LeaveRoom(); err != nil {
+		log.Warningf("Unable to exit room on disconnect: %s", err)
 	}
 }
 
 // OnConnect runs whever a new user connects
 func (user *User) OnConnect() {
+	metrics.ConnectedClients.Inc()
+
 	// Start timeout timer for login?
 }
 
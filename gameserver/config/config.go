@@ -1,46 +1,182 @@
 package config
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"path/filepath"
+	"flag"
+
+	"github.com/zeroZshadow/rose-example/shared/configload"
 )
 
-//GlobalConfig loaded configuration
-var GlobalConfig *Config
+// current the config in use
+var current configload.Current
+
+// Get the config in use. Don't hold on to it, a reload replaces it.
+func Get() *Config {
+	cfg, _ := current.Get().(*Config)
+	return cfg
+}
+
+// Set the config in use
+func Set(cfg *Config) {
+	current.Set(cfg)
+}
+
+// RoomType the tick rates of a kind of room, in ticks per second
+type RoomType struct {
+	WaitingTickRate int `json:"waitingtickrate"`
+	TickRate        int `json:"tickrate"`
+}
 
 // Config describes the whole process of generating sitemap
+// Fields tagged reload are picked up by Reload, the rest needs a restart.
+// Rooms keep the settings they were created with, reloads only change rooms created after them.
 type Config struct {
-	Address       string `json:"address"`
-	MasterAddress string `json:"masteraddress"`
-	Region        string `json:"region"`
-	Database      string `json:"database"`
-	Name          string `json:"name"`
+	Address         string              `json:"address"`
+	MasterAddress   string              `json:"masteraddress"`
+	Region          string              `json:"region"`
+	Database        string              `json:"database" secret:"true"` // Deprecated: ignored, nodes keep no storage
+	Name            string              `json:"name"`
+	TickRate        int                 `json:"tickrate" reload:"true"`
+	WaitingTickRate int                 `json:"waitingtickrate" reload:"true"`
+	RoomTypes       map[string]RoomType `json:"roomtypes" reload:"true"`       // Types clients can ask for by name, besides the default above
+	MaxTickRate     int                 `json:"maxtickrate" reload:"true"`     // Fastest tick rate clients can ask for, 0 doesn't let them
+	EmptyRoomTTL    int                 `json:"emptyroomttl" reload:"true"`    // Seconds, 0 disables
+	MaxRoomLifetime int                 `json:"maxroomlifetime" reload:"true"` // Seconds, 0 disables
+	ChatMaxLength   int                 `json:"chatmaxlength" reload:"true"`
+	ChatRate        float64             `json:"chatrate" reload:"true"` // Messages per second
+	ChatBurst       int                 `json:"chatburst" reload:"true"`
+	ChatFilter      []string            `json:"chatfilter" reload:"true"`
+	ChatFilterMode  string              `json:"chatfiltermode" reload:"true"` // "mask" or "reject"
+	Admins          []uint64            `json:"admins" reload:"true"`
+	MaxMute         int64               `json:"maxmute" reload:"true"` // Seconds, longer mutes are cut short
+	ChatHistorySize int                 `json:"chathistorysize" reload:"true"`
+	SpectatorDelay  int                 `json:"spectatordelay" reload:"true"` // Milliseconds, 0 disables
+	ReplayDir       string              `json:"replaydir" reload:"true"`      // Empty disables recording
+	AdminAddress    string              `json:"adminaddress"`                 // Empty disables the admin API and metrics
+	AdminToken      string              `json:"admintoken" secret:"true"`
+	ShutdownTimeout int                 `json:"shutdowntimeout" reload:"true"` // Seconds
+
+	configload.Common
 }
 
 // New create new Config with default values
 func New() *Config {
 	return &Config{
-		Address:       ":0",
-		MasterAddress: "ws://localhost:8080/cluster",
-		Region:        "EU",
-		Database:      "user:password@tcp(localhost.net:3306)/game_db?charset=utf8&parseTime=true",
-		Name:          "GameServer1",
+		Address:         ":0",
+		MasterAddress:   "ws://localhost:8080/cluster",
+		Region:          "EU",
+		Name:            "GameServer1",
+		TickRate:        60,
+		WaitingTickRate: 10,
+		RoomTypes:       map[string]RoomType{},
+		MaxTickRate:     60,
+		EmptyRoomTTL:    60,
+		MaxRoomLifetime: 4 * 60 * 60,
+		ChatMaxLength:   256,
+		ChatRate:        1,
+		ChatBurst:       5,
+		ChatFilter:      []string{},
+		ChatFilterMode:  "mask",
+		Admins:          []uint64{},
+		MaxMute:         86400,
+		ChatHistorySize: 50,
+		SpectatorDelay:  0,
+		ReplayDir:       "",
+		AdminAddress:    "",
+		AdminToken:      "",
+		ShutdownTimeout: 30,
+		Common:          configload.DefaultCommon(),
 	}
 }
 
-// FromFile parses file into a Config
-func (cfg *Config) FromFile(file string) error {
-	path, err := filepath.Abs(file)
-	if err != nil {
-		return err
+// envPrefix environment variables overriding the config start with this
+const envPrefix = "GAMESERVER"
+
+// RegisterFlags add a flag for every config field, so they can be overridden from the command line
+func RegisterFlags(flags *flag.FlagSet) {
+	configload.RegisterFlags(flags, New())
+}
+
+// Load the config in layers: defaults, the file if given, environment variables and flags.
+// The result is validated, any problem is returned as an error.
+func Load(file string, flags *flag.FlagSet) (*Config, error) {
+	cfg := New()
+	if err := configload.Load(cfg, file, envPrefix, flags); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
+
+// Reload load the config again and swap in the settings that are safe to change while running.
+// An invalid config is rejected and the current one stays in use.
+func Reload(file string, flags *flag.FlagSet) error {
+	return current.Reload(New(), file, envPrefix, flags)
+}
 
-	fileC, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
+// Validate check the config makes sense
+func (cfg *Config) Validate() error {
+	var errs configload.Errors
+
+	if cfg.Address == "" {
+		errs.Add("address is required")
+	}
+	if cfg.MasterAddress == "" {
+		errs.Add("masteraddress is required")
 	}
+	if cfg.Region == "" {
+		errs.Add("region is required")
+	}
+
+	if cfg.TickRate <= 0 {
+		errs.Add("tickrate must be positive")
+	}
+	if cfg.WaitingTickRate <= 0 {
+		errs.Add("waitingtickrate must be positive")
+	}
+	for name, roomType := range cfg.RoomTypes {
+		if name == "" {
+			errs.Add("roomtypes can't have a type without a name")
+		}
+		if roomType.TickRate <= 0 || roomType.WaitingTickRate <= 0 {
+			errs.Add("roomtypes %q needs a positive tickrate and waitingtickrate", name)
+		}
+	}
+	if cfg.MaxTickRate < 0 {
+		errs.Add("maxtickrate can't be negative")
+	}
+	if cfg.EmptyRoomTTL < 0 {
+		errs.Add("emptyroomttl can't be negative")
+	}
+	if cfg.MaxRoomLifetime < 0 {
+		errs.Add("maxroomlifetime can't be negative")
+	}
+
+	if cfg.ChatMaxLength < 0 {
+		errs.Add("chatmaxlength can't be negative")
+	}
+	if cfg.ChatRate < 0 {
+		errs.Add("chatrate can't be negative")
+	}
+	if cfg.ChatRate > 0 && cfg.ChatBurst < 1 {
+		errs.Add("chatburst must be at least 1 when chatrate is set")
+	}
+	if cfg.ChatFilterMode != "mask" && cfg.ChatFilterMode != "reject" {
+		errs.Add("chatfiltermode must be \"mask\" or \"reject\", not %q", cfg.ChatFilterMode)
+	}
+	if cfg.MaxMute <= 0 {
+		errs.Add("maxmute must be positive")
+	}
+	if cfg.ChatHistorySize < 0 {
+		errs.Add("chathistorysize can't be negative")
+	}
+
+	if cfg.SpectatorDelay < 0 {
+		errs.Add("spectatordelay can't be negative")
+	}
+	if cfg.ShutdownTimeout < 0 {
+		errs.Add("shutdowntimeout can't be negative")
+	}
+
+	cfg.Common.Validate(&errs)
 
-	err = json.Unmarshal(fileC, cfg)
-	return err
+	return errs.Err()
 }
@@ -5,27 +5,36 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"time"
 
 	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/admin"
 	"github.com/zeroZshadow/rose-example/gameserver/client"
 	"github.com/zeroZshadow/rose-example/gameserver/config"
 	"github.com/zeroZshadow/rose-example/gameserver/master"
 	"github.com/zeroZshadow/rose-example/gameserver/node"
 	"github.com/zeroZshadow/rose-example/gameserver/room"
 	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+	"github.com/zeroZshadow/rose-example/shared/configload"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
 
 	"github.com/op/go-logging"
 )
 
 var (
-	configFile string
-	logFile    string
-	log        = logging.MustGetLogger("global")
+	configFile  string
+	logFile     string
+	printConfig bool
+	log         = logging.MustGetLogger("main")
 )
 
 func init() {
 	flag.StringVar(&configFile, "config", "", "Path to config file")
 	flag.StringVar(&logFile, "log", "", "Path to log file")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective config, with secrets redacted, and exit")
+	config.RegisterFlags(flag.CommandLine)
 }
 
 func main() {
@@ -33,38 +42,94 @@ func main() {
 	flag.Parse()
 
 	// Setup logging
-	shared.InitLogger(logFile)
+	// Logging only goes to stderr and the file as text until the config is loaded
+	if err := shared.InitLogger(shared.LogOptions{File: logFile}); err != nil {
+		log.Fatalf("Unable to setup logging!\n%s", err.Error())
+	}
 	defer shared.CloseLogger()
 
-	// Initialize configuration
-	cfg := config.New()
-
-	// Overwrite configuration if file was passed
+	// Load configuration, a broken config is not something to run with
+	cfg, err := config.Load(configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Unable to load config!\n%s", err.Error())
+	}
 	if configFile != "" {
-		err := cfg.FromFile(configFile)
+		log.Noticef("Loaded config from file: %s", configFile)
+	}
+	if cfg.Database != "" {
+		log.Warning("The database setting is deprecated and ignored, nodes keep no storage")
+	}
+
+	// Only show the config if that's all we were asked to do
+	if printConfig {
+		data, err := configload.Print(cfg)
 		if err != nil {
-			log.Warningf("Error while loading config: %s", err.Error())
-			log.Notice("Loaded default config")
-		} else {
-			log.Noticef("Loaded config from file: %s", configFile)
+			log.Fatalf("Unable to print config!\n%s", err.Error())
 		}
-	} else {
-		log.Notice("Loaded default config")
+		fmt.Println(string(data))
+		return
 	}
 
 	// Set as global config
-	config.GlobalConfig = cfg
+	config.Set(cfg)
+
+	// Setup logging the way the config asks for
+	err = shared.InitLogger(shared.LogOptions{
+		File:        logFile,
+		Format:      cfg.LogFormat,
+		FileLevel:   cfg.LogFileLevel,
+		MaxSize:     cfg.LogMaxSize,
+		MaxBackups:  cfg.LogMaxBackups,
+		MaxAge:      cfg.LogMaxAge,
+		SampleEvery: cfg.LogSampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("Unable to setup logging!\n%s", err.Error())
+	}
+	err = shared.SetLogLevels(cfg.LogLevel, cfg.LogLevels)
+	if err != nil {
+		log.Fatalf("Unable to set log levels!\n%s", err.Error())
+	}
+
+	// Setup tracing, spans are flushed when stopping
+	err = tracing.Setup(tracing.Options{
+		Service:     "gameserver",
+		Exporter:    cfg.TraceExporter,
+		File:        cfg.TraceFile,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Unable to setup tracing!\n%s", err.Error())
+	}
+	defer tracing.Shutdown(5 * time.Second)
+
+	// Keep a trail of security relevant events, separate from the logs
+	if cfg.AuditFile != "" {
+		sink, err := audit.OpenFile(cfg.AuditFile)
+		if err != nil {
+			log.Fatalf("Unable to open audit file!\n%s", err.Error())
+		}
+		audit.SetSink(cfg.Name, sink)
+		defer audit.Close()
+	}
+
+	// Pick up config changes without a restart
+	err = configload.Watch(configFile, reloadConfig)
+	if err != nil {
+		log.Warningf("Unable to watch config file: %s", err.Error())
+	}
 
 	// Setup handlers
 	SetupMessageHandlers()
 	room.SetupMessageHandlers()
+	master.SetupMessageHandlers()
 
 	// Create Server without origin checking and listen on /ws
 	server := rose.New(nil)
 	server.Listen("/ws", client.New)
 
 	// Setup listener
-	err := server.Serve(cfg.Address)
+	err = server.Serve(cfg.Address)
 	if err != nil {
 		log.Fatalf("Unable to start server!\n%s", err.Error())
 	}
@@ -81,7 +146,42 @@ func main() {
 	node.Instantiate(server, cfg.Region, cfg.MasterAddress, port, master.New)
 	node.Instance.Start()
 
-	// Wait for things to Close
-	server.Wait()
+	// Start the local admin API, readiness follows the node
+	if cfg.AdminAddress != "" {
+		if cfg.AdminToken == "" {
+			log.Warning("No admin token configured, only serving metrics and health checks")
+		}
+		err = admin.Serve(cfg.AdminAddress, cfg.AdminToken)
+		if err != nil {
+			log.Fatalf("Unable to start admin API!\n%s", err.Error())
+		}
+	}
+
+	// Wait for things to Close, or to be asked to stop
+	if sig := shared.WaitForShutdown(server); sig != nil {
+		log.Noticef("Received %s, shutting down", sig)
+
+		// Take no new rooms, let the current ones wind down and unregister
+		node.Instance.SetDraining(true)
+		room.Shutdown(time.Duration(config.Get().ShutdownTimeout) * time.Second)
+
+		// rose can't stop listening, returning closes whatever connections are left
+		node.Instance.Stop()
+	}
 	log.Info("Stopped.")
 }
+
+// reloadConfig load the config again, keeping the current one if the new one is broken
+func reloadConfig() {
+	err := config.Reload(configFile, flag.CommandLine)
+	if err != nil {
+		log.Errorf("Unable to reload config, keeping the current one!\n%s", err.Error())
+		return
+	}
+
+	cfg := config.Get()
+	err = shared.SetLogLevels(cfg.LogLevel, cfg.LogLevels)
+	if err != nil {
+		log.Errorf("Unable to set log levels!\n%s", err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+package master
+
+import (
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/node"
+	"github.com/zeroZshadow/rose-example/gameserver/room"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+// SetupMessageHandlers Fill the message map for the master
+func SetupMessageHandlers() {
+	messageMap[pb.MessageType_RegisterNode] = handleRegisterNode
+	messageMap[pb.MessageType_CloseRoom] = handleCloseRoom
+	messageMap[pb.MessageType_KickUser] = handleKickUser
+	messageMap[pb.MessageType_DrainNode] = handleDrainNode
+}
+
+func handleRegisterNode(user *User, messageType pb.MessageType, message []byte) {
+	input := &pb.RegisterNodeResponse{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		log.Errorf("unmarshaling error: %s", err)
+		return
+	}
+
+	node.Instance.SetRegistered(input.Success)
+	if input.Success {
+		log.Notice("Registered with the master")
+	} else {
+		log.Warning("Master refused our registration")
+	}
+}
+
+func handleCloseRoom(user *User, messageType pb.MessageType, message []byte) {
+	input := &pb.CloseRoomRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		log.Errorf("unmarshaling error: %s", err)
+		return
+	}
+
+	roomID := rose.RoomID(input.Id)
+	ok := room.Do(roomID, func(r *room.Room) {
+		r.Close(input.Reason)
+	})
+	if !ok {
+		log.Warningf("Master asked to close unknown room %d", roomID)
+		return
+	}
+
+	log.Noticef("Master closed room %d, reason: %s", roomID, input.Reason)
+	audit.Record("room.close", "master", audit.Room(input.Id), map[string]string{"reason": input.Reason.String()})
+}
+
+func handleKickUser(user *User, messageType pb.MessageType, message []byte) {
+	input := &pb.KickUserRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		log.Errorf("unmarshaling error: %s", err)
+		return
+	}
+
+	roomID := rose.RoomID(input.RoomId)
+	userID := rose.UserID(input.UserId)
+	ok := room.Do(roomID, func(r *room.Room) {
+		r.Kick(userID, input.Reason)
+	})
+	if !ok {
+		log.Warningf("Master asked to kick user %d from unknown room %d", userID, roomID)
+		return
+	}
+
+	audit.Record("user.kick", "master", audit.User(input.UserId), map[string]string{
+		"room":   strconv.FormatUint(input.RoomId, 10),
+		"reason": input.Reason,
+	})
+}
+
+func handleDrainNode(user *User, messageType pb.MessageType, message []byte) {
+	input := &pb.DrainNodeRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		log.Errorf("unmarshaling error: %s", err)
+		return
+	}
+
+	node.Instance.SetDraining(input.Drain)
+	log.Noticef("Draining: %t", input.Drain)
+	audit.Record("node.drain", "master", "", map[string]string{"drain": strconv.FormatBool(input.Drain)})
+}
@@ -4,9 +4,14 @@ import (
 	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/gameserver/node"
+	"github.com/zeroZshadow/rose-example/messages/pb"
 )
 
-var log = logging.MustGetLogger("global")
+type userMessageHandler func(*User, pb.MessageType, []byte)
+
+// MessageMap Map of messageType handlers
+var messageMap = make(map[pb.MessageType]userMessageHandler)
+var log = logging.MustGetLogger("master")
 
 // User Connection to master server
 type User struct {
@@ -15,16 +20,22 @@ type User struct {
 }
 
 // HandlePacket Implements rose.User.HandlePacket
-func (user *User) HandlePacket(messageType rose.MessageType, message []byte) {
+func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
+	messageType := pb.MessageType(msgType)
+
+	// Find handler for message type, run if available
+	if handler, ok := messageMap[messageType]; ok {
+		handler(user, messageType, message)
+		return
+	}
 
+	// No handler found
+	log.Warningf("Unhandled master message %d!", messageType)
 }
 
 // OnDisconnect Implements rose.User.OnDisconnect
 func (user *User) OnDisconnect(err error) {
-	node.Instance.Lock()
-	defer node.Instance.Unlock()
-
-	node.Instance.Master = nil
+	node.Instance.LostMaster()
 	log.Warning("Lost connection to master, retrying.")
 }
 
@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/gameserver/metrics"
 	"github.com/zeroZshadow/rose-example/gameserver/node"
 	"github.com/zeroZshadow/rose-example/gameserver/room"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("gameserver")
+
 // SetupMessageHandlers Fill the message map for the client
 func SetupMessageHandlers() {
 	client.MessageMap[pb.MessageType_CreateRoom] = handleRoomRequest
@@ -20,6 +32,7 @@ func handleRoomRequest(user *client.User, messageType pb.MessageType, message []
 	input := &pb.RoomRequest{}
 	err := proto.Unmarshal(message, input)
 	if err != nil {
+		countRoomRequest(messageType, "bad_request")
 		sendRoomResponse(user, messageType, false, rose.RoomID(input.Id))
 		return err
 	}
@@ -27,32 +40,72 @@ func handleRoomRequest(user *client.User, messageType pb.MessageType, message []
 	roomID := rose.RoomID(input.Id)
 
 	// Does the user already have a room? fail!
-	if user.Room != nil {
+	if user.CurrentRoom() != nil {
 		log.Warning("User already in a room")
+		countRoomRequest(messageType, "already_in_room")
 		sendRoomResponse(user, messageType, false, roomID)
 		user.Disconnect()
 		return nil
 	}
 
 	// Verify authentication
-	userID, err := node.Instance.VerifyAuthentication(roomID, input.Authtoken)
+	verifyStart := time.Now()
+	request, err := node.Instance.VerifyAuthentication(roomID, input.Authtoken)
 	if err != nil {
+		// Without a valid token there's no trace to continue
+		_, span := tracer.Start(context.Background(), "VerifyAuthentication", trace.WithTimestamp(verifyStart))
+		tracing.Fail(span, err)
+		span.End()
+
 		log.Warningf("Invalid authentication token %s", err)
+		audit.RecordLimited("token.reject", "", audit.Room(uint64(roomID)), map[string]string{"reason": err.Error()})
+		metrics.TokenFailures.Inc()
+		countRoomRequest(messageType, "invalid_token")
 		sendRoomResponse(user, messageType, false, roomID)
 		user.Disconnect()
 		return nil
 	}
 
 	// Since the request is valid, we can use this to automatically login the userID
-	user.ID = userID
+	user.ID = request.UserID
+	user.Profile = request.Profile
+	user.Spectator = request.Spectator
+	audit.Record("user.login", audit.User(uint64(user.ID)), audit.Room(uint64(roomID)), map[string]string{
+		"request":   messageType.String(),
+		"spectator": strconv.FormatBool(user.Spectator),
+	})
+
+	// Continue the master's trace, from when it handed out the token
+	ctx, span := tracer.Start(tracing.Extract(request.TraceParent), "RoomRequest", trace.WithTimestamp(time.Unix(0, request.Timestamp)))
+	span.SetAttributes(
+		attribute.Int64("user.id", int64(user.ID)),
+		attribute.Int64("room.id", int64(roomID)),
+		attribute.String("request", messageType.String()),
+	)
+	defer span.End()
+
+	_, connect := tracer.Start(ctx, "ClientConnect", trace.WithTimestamp(time.Unix(0, request.Timestamp)))
+	connect.End(trace.WithTimestamp(verifyStart))
+	_, verify := tracer.Start(ctx, "VerifyAuthentication", trace.WithTimestamp(verifyStart))
+	verify.End()
+
+	// The room picks the trace up from the user when they join
+	user.TraceParent = tracing.Inject(ctx)
 
 	var result bool
 	switch messageType {
 	case pb.MessageType_CreateRoom:
-		result = createRoom(user, messageType, roomID)
+		// Someone has to play in a new room
+		if user.Spectator {
+			log.Warningf("Spectator %d tried to create room %d", user.ID, roomID)
+			countRoomRequest(messageType, "spectator")
+			break
+		}
+		result = createRoom(ctx, user, messageType, roomID, request)
 	case pb.MessageType_JoinRoom:
-		result = joinRoom(user, messageType, roomID)
+		result = joinRoom(ctx, user, messageType, roomID)
 	}
+	span.SetAttributes(attribute.Bool("success", result))
 
 	// Response
 	sendRoomResponse(user, messageType, result, roomID)
@@ -60,11 +113,30 @@ func handleRoomRequest(user *client.User, messageType pb.MessageType, message []
 	return nil
 }
 
-func createRoom(user *client.User, messageType pb.MessageType, roomID rose.RoomID) bool {
+func createRoom(ctx context.Context, user *client.User, messageType pb.MessageType, roomID rose.RoomID, request *shared.RoomRequest) bool {
+	_, span := tracer.Start(ctx, "createRoom")
+	defer span.End()
+
+	// Rooms being created as the master tells us to drain were placed before it knew
+	if node.Instance.Draining() {
+		log.Warningf("Refusing to create room %d while draining", roomID)
+		countRoomRequest(messageType, "draining")
+		return false
+	}
+
+	// Only room types and tick rates this node is configured for
+	roomType, err := room.LookupType(request.RoomType, request.TickRate)
+	if err != nil {
+		log.Warningf("Refusing to create room %d: %s", roomID, err)
+		countRoomRequest(messageType, "invalid_type")
+		return false
+	}
+
 	// Create a new room
-	roomfront := rose.RoomLobby.NewRoom(roomID, room.New)
+	roomfront := rose.RoomLobby.NewRoom(roomID, room.New(roomType, request.InviteOnly))
 	if roomfront == nil {
 		log.Errorf("Failed to create room %d", roomID)
+		countRoomRequest(messageType, "create_failed")
 		return false
 	}
 
@@ -74,27 +146,39 @@ func createRoom(user *client.User, messageType pb.MessageType, roomID rose.RoomI
 	room, err := rose.RoomLobby.JoinRoom(roomID, user)
 	if err != nil {
 		log.Errorf("Failed to join created room %d", roomID)
+		countRoomRequest(messageType, "join_failed")
 		return false
 	}
 
-	user.Room = room
+	user.SetRoom(room)
+	countRoomRequest(messageType, "ok")
 
 	return true
 }
 
-func joinRoom(user *client.User, messageType pb.MessageType, roomID rose.RoomID) bool {
+func joinRoom(ctx context.Context, user *client.User, messageType pb.MessageType, roomID rose.RoomID) bool {
+	_, span := tracer.Start(ctx, "joinRoom")
+	defer span.End()
+
 	// Join existing room
 	room, err := rose.RoomLobby.JoinRoom(roomID, user)
 	if err != nil {
 		log.Errorf("Failed to join room %d", roomID)
+		countRoomRequest(messageType, "join_failed")
 		return false
 	}
 
-	user.Room = room
+	user.SetRoom(room)
+	countRoomRequest(messageType, "ok")
 
 	return true
 }
 
+// countRoomRequest keep track of how room requests turn out
+func countRoomRequest(requestType pb.MessageType, result string) {
+	metrics.RoomRequests.WithLabelValues(requestType.String(), result).Inc()
+}
+
 func sendRoomResponse(user *client.User, messageType pb.MessageType, success bool, roomID rose.RoomID) {
 	// Create response
 	response := &pb.RoomResponse{
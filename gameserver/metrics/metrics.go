@@ -0,0 +1,63 @@
+// Package metrics holds the Prometheus metrics of the game server
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ConnectedClients number of clients connected to this node
+	ConnectedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "gameserver",
+		Name:      "connected_clients",
+		Help:      "Number of clients connected to this node.",
+	})
+
+	// Rooms number of rooms on this node, by state
+	Rooms = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "gameserver",
+		Name:      "rooms",
+		Help:      "Number of rooms on this node, by state.",
+	}, []string{"state"})
+
+	// RoomRequests attempts to create or join a room, by request type and result
+	RoomRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gameserver",
+		Name:      "room_requests_total",
+		Help:      "Attempts to create or join a room, by request type and result.",
+	}, []string{"request", "result"})
+
+	// TokenFailures room tokens that failed verification
+	TokenFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "gameserver",
+		Name:      "token_verification_failures_total",
+		Help:      "Room tokens presented by clients that failed verification.",
+	})
+
+	// Messages received from clients, by where they were handled and message type
+	Messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gameserver",
+		Name:      "messages_total",
+		Help:      "Messages received from clients, by where they were handled (client or room) and message type.",
+	}, []string{"scope", "type"})
+
+	// HandlerDuration time spent handling messages, by where they were handled and message type
+	HandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gameserver",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent handling a message, by where it was handled (client or room) and message type.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"scope", "type"})
+
+	// TickDuration time rooms spend per tick
+	TickDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "gameserver",
+		Name:      "room_tick_duration_seconds",
+		Help:      "Time a room spends on a single tick.",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 14),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ConnectedClients, Rooms, RoomRequests, TokenFailures, Messages, HandlerDuration, TickDuration)
+}
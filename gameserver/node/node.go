@@ -26,7 +26,7 @@ var (
 	// Instance a global node instance
 	Instance          *Node
 	masterConstructor rose.UserConstructor
-	log               = logging.MustGetLogger("global")
+	log               = logging.MustGetLogger("node")
 )
 
 // Node structure represends the connection to the master server
@@ -42,6 +42,10 @@ type Node struct {
 	address     string
 	retryTicker *time.Ticker
 	retryQuit   chan struct{}
+	draining    bool
+
+	// registered the master accepted our registration, connecting alone isn't enough
+	registered bool
 }
 
 // Instantiate create a new global node instance
@@ -92,10 +96,19 @@ func (node *Node) Start() {
 	}()
 }
 
-// Stop stop the node from connecting to the master
+// Stop stop the node from connecting to the master, and disconnect from it
 func (node *Node) Stop() {
 	node.retryTicker.Stop()
 	node.retryQuit <- struct{}{}
+
+	// Disconnecting calls back into the node, don't hold the lock
+	node.RLock()
+	master := node.Master
+	node.RUnlock()
+
+	if master != nil {
+		master.Disconnect()
+	}
 }
 
 func (node *Node) register(region string, port uint64) {
@@ -164,15 +177,65 @@ func (node *Node) register(region string, port uint64) {
 	log.Noticef("Registered game node with ip: %s.", addressString)
 }
 
-// VerifyAuthentication verify that the auth block from the user is correct, return the user id inside
-func (node *Node) VerifyAuthentication(roomID rose.RoomID, auth []byte) (rose.UserID, error) {
+// SetDraining stop or resume accepting new rooms, rooms already running are unaffected
+func (node *Node) SetDraining(draining bool) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.draining = draining
+}
+
+// Draining returns true if the node is not accepting new rooms
+func (node *Node) Draining() bool {
+	node.RLock()
+	defer node.RUnlock()
+
+	return node.draining
+}
+
+// SetRegistered remember whether the master accepted our registration
+func (node *Node) SetRegistered(registered bool) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.registered = registered
+}
+
+// LostMaster forget the master after the connection dropped, so we register again
+func (node *Node) LostMaster() {
+	node.Lock()
+	defer node.Unlock()
+
+	node.Master = nil
+	node.registered = false
+}
+
+// Ready returns why the node can't take rooms, or nil if it can
+func (node *Node) Ready() error {
+	node.RLock()
+	defer node.RUnlock()
+
+	if node.Master == nil {
+		return errors.New("not connected to the master")
+	}
+	if !node.registered {
+		return errors.New("not registered with the master")
+	}
+	if node.draining {
+		return errors.New("draining")
+	}
+	return nil
+}
+
+// VerifyAuthentication verify that the auth block from the user is correct, return the room request inside
+func (node *Node) VerifyAuthentication(roomID rose.RoomID, auth []byte) (*shared.RoomRequest, error) {
 	// Generate block
 	block, err := aes.NewCipher(node.cipherkey)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	if len(auth) < aes.BlockSize {
-		return 0, errors.New("ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 
 	// Decrypt authentication data
@@ -185,15 +248,15 @@ func (node *Node) VerifyAuthentication(roomID rose.RoomID, auth []byte) (rose.Us
 	request := &shared.RoomRequest{}
 	err = json.Unmarshal(data, request)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// If the data does not match, fail the verification
 	if roomID != request.RoomID {
-		return 0, errors.New("Wrong roomid!")
+		return nil, errors.New("Wrong roomid!")
 	}
 
 	// TODO Something with the timestamp
 
-	return request.UserID, nil
+	return request, nil
 }
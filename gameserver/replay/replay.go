@@ -0,0 +1,292 @@
+// Package replay reads and writes match replay files.
+//
+// A replay starts with a header: the magic bytes "RRPL", a format version byte,
+// the room id as uvarint, the recording start as varint unix milliseconds and the settings
+// of the room (uvarint length followed by the bytes, JSON as written by the room).
+// Before version 3 the start was in unix nanoseconds and there were no settings.
+// It is followed by records until the end of the file, each made up of the tick (uvarint),
+// milliseconds since the start (uvarint), direction (byte), user id (uvarint, 0 for broadcasts),
+// message type (uvarint), payload length (uvarint) and the payload itself.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/shared"
+)
+
+const (
+	magic = "RRPL"
+	// Version of the replay format written by this package, 2 added events, 3 the room settings
+	Version = 3
+	// maxPayload guards against allocating garbage lengths from a corrupt file
+	maxPayload = 16 * 1024 * 1024
+)
+
+// Direction of a recorded message
+type Direction byte
+
+// Record directions
+const (
+	// Inbound a message a user sent to the room
+	Inbound Direction = iota
+	// Outbound a message the room sent, to a user or everyone
+	Outbound
+	// Join a user joined the room, the payload is a JSON encoded Join
+	Join
+	// Leave a user left the room
+	Leave
+	// Event something happened to the room that no user caused, like an admin closing it.
+	// The message type and payload are those of the message that asks for it.
+	Event
+)
+
+func (direction Direction) String() string {
+	switch direction {
+	case Inbound:
+		return "in"
+	case Outbound:
+		return "out"
+	case Join:
+		return "join"
+	case Leave:
+		return "leave"
+	case Event:
+		return "event"
+	}
+	return fmt.Sprintf("direction(%d)", byte(direction))
+}
+
+// ErrInvalidFile the file is not a replay, or written by a newer version
+var ErrInvalidFile = errors.New("replay: invalid replay file")
+
+// Header describes the recorded room
+type Header struct {
+	Version byte
+	RoomID  rose.RoomID
+	Start   time.Time // Millisecond precision, like the offsets of the records
+	// Settings the room ran with, so it can be played back without the config it was recorded with.
+	// Empty before version 3.
+	Settings []byte
+}
+
+// Record a single recorded event
+type Record struct {
+	Tick        uint64
+	Offset      time.Duration // Since the start of the recording, millisecond precision
+	Direction   Direction
+	UserID      rose.UserID
+	MessageType uint64
+	Payload     []byte
+}
+
+// JoinInfo what the room needs to know about a user to let them join again during playback
+type JoinInfo struct {
+	Profile   shared.PlayerProfile
+	Spectator bool
+}
+
+// Writer writes a replay file
+type Writer struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	start   time.Time
+	scratch [binary.MaxVarintLen64]byte
+}
+
+// Create start a new replay file at path, truncating any existing file.
+// The start is cut down to milliseconds, offsets are counted from there.
+func Create(path string, roomID rose.RoomID, start time.Time, settings []byte) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	startMillis := start.UnixNano() / int64(time.Millisecond)
+	writer := &Writer{
+		file:   file,
+		buffer: bufio.NewWriter(file),
+		start:  time.Unix(0, startMillis*int64(time.Millisecond)),
+	}
+
+	writer.buffer.WriteString(magic)
+	writer.buffer.WriteByte(Version)
+	writer.uvarint(uint64(roomID))
+	writer.varint(startMillis)
+	writer.uvarint(uint64(len(settings)))
+	writer.buffer.Write(settings)
+
+	if err := writer.buffer.Flush(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Write append a record, the offset is taken from the given time
+func (writer *Writer) Write(tick uint64, now time.Time, direction Direction, userID rose.UserID, messageType uint64, payload []byte) error {
+	offset := now.Sub(writer.start)
+	if offset < 0 {
+		offset = 0
+	}
+
+	writer.uvarint(tick)
+	writer.uvarint(uint64(offset / time.Millisecond))
+	writer.buffer.WriteByte(byte(direction))
+	writer.uvarint(uint64(userID))
+	writer.uvarint(messageType)
+	writer.uvarint(uint64(len(payload)))
+	_, err := writer.buffer.Write(payload)
+	return err
+}
+
+// Close flush and close the file
+func (writer *Writer) Close() error {
+	if err := writer.buffer.Flush(); err != nil {
+		writer.file.Close()
+		return err
+	}
+	return writer.file.Close()
+}
+
+func (writer *Writer) uvarint(value uint64) {
+	n := binary.PutUvarint(writer.scratch[:], value)
+	writer.buffer.Write(writer.scratch[:n])
+}
+
+func (writer *Writer) varint(value int64) {
+	n := binary.PutVarint(writer.scratch[:], value)
+	writer.buffer.Write(writer.scratch[:n])
+}
+
+// Reader reads a replay file record by record
+type Reader struct {
+	file   *os.File
+	buffer *bufio.Reader
+	header Header
+}
+
+// Open a replay file and read its header
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{
+		file:   file,
+		buffer: bufio.NewReader(file),
+	}
+
+	if err := reader.readHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+func (reader *Reader) readHeader() error {
+	prefix := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(reader.buffer, prefix); err != nil {
+		return ErrInvalidFile
+	}
+	if string(prefix[:len(magic)]) != magic || prefix[len(magic)] == 0 || prefix[len(magic)] > Version {
+		return ErrInvalidFile
+	}
+
+	roomID, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		return ErrInvalidFile
+	}
+	start, err := binary.ReadVarint(reader.buffer)
+	if err != nil {
+		return ErrInvalidFile
+	}
+
+	reader.header = Header{
+		Version: prefix[len(magic)],
+		RoomID:  rose.RoomID(roomID),
+		Start:   time.Unix(0, start),
+	}
+	if reader.header.Version < 3 {
+		return nil
+	}
+
+	reader.header.Start = time.Unix(0, start*int64(time.Millisecond))
+	length, err := binary.ReadUvarint(reader.buffer)
+	if err != nil || length > maxPayload {
+		return ErrInvalidFile
+	}
+	reader.header.Settings = make([]byte, length)
+	if _, err := io.ReadFull(reader.buffer, reader.header.Settings); err != nil {
+		return ErrInvalidFile
+	}
+	return nil
+}
+
+// Header the header of the replay
+func (reader *Reader) Header() Header {
+	return reader.header
+}
+
+// Next read the next record, returns io.EOF once all records are read
+func (reader *Reader) Next() (*Record, error) {
+	tick, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		// A clean end of file between records
+		return nil, err
+	}
+
+	// Anything missing past this point means the file was cut off
+	offset, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	direction, err := reader.buffer.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	userID, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	messageType, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	length, err := binary.ReadUvarint(reader.buffer)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if length > maxPayload {
+		return nil, ErrInvalidFile
+	}
+
+	record := &Record{
+		Tick:        tick,
+		Offset:      time.Duration(offset) * time.Millisecond,
+		Direction:   Direction(direction),
+		UserID:      rose.UserID(userID),
+		MessageType: messageType,
+		Payload:     make([]byte, length),
+	}
+	if _, err := io.ReadFull(reader.buffer, record.Payload); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return record, nil
+}
+
+// Close the file
+func (reader *Reader) Close() error {
+	return reader.file.Close()
+}
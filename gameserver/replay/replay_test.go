@@ -0,0 +1,132 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "room.replay")
+	start := time.Unix(1600000000, 123456789)
+	settings := []byte(`{"tickrate":20}`)
+
+	writer, err := Create(path, 42, start, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []Record{
+		{Tick: 0, Offset: 0, Direction: Join, UserID: 1, Payload: []byte(`{}`)},
+		{Tick: 3, Offset: 150 * time.Millisecond, Direction: Inbound, UserID: 1, MessageType: 5, Payload: []byte("hello")},
+		{Tick: 3, Offset: 150 * time.Millisecond, Direction: Outbound, MessageType: 5, Payload: []byte("hello")},
+		{Tick: 9, Offset: 2 * time.Second, Direction: Event, MessageType: 39, Payload: []byte{}},
+	}
+	for _, record := range records {
+		if err := writer.Write(record.Tick, start.Add(record.Offset), record.Direction, record.UserID, record.MessageType, record.Payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// The start is kept in milliseconds, like the offsets
+	header := reader.Header()
+	if header.Version != Version || header.RoomID != 42 || !header.Start.Equal(start.Truncate(time.Millisecond)) || !bytes.Equal(header.Settings, settings) {
+		t.Errorf("unexpected header %+v", header)
+	}
+
+	for i, want := range records {
+		got, err := reader.Next()
+		if err != nil {
+			t.Fatalf("record %d: %s", i, err)
+		}
+		if got.Tick != want.Tick || got.Offset != want.Offset || got.Direction != want.Direction ||
+			got.UserID != want.UserID || got.MessageType != want.MessageType || !bytes.Equal(got.Payload, want.Payload) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want, *got)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last record, got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "room.replay")
+
+	writer, err := Create(path, 1, time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(1, time.Now(), Inbound, 1, 5, []byte("cut off"))
+	writer.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data[:len(data)-3], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a cut off record, got %v", err)
+	}
+}
+
+func TestVersion2Header(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "room.replay")
+	start := time.Unix(1600000000, 123456789)
+
+	// Version 2 wrote the start in nanoseconds and had no settings
+	var nanos [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(nanos[:], start.UnixNano())
+	data := append(append([]byte(magic), 2, 42), nanos[:n]...)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	header := reader.Header()
+	if header.Version != 2 || header.RoomID != 42 || !header.Start.Equal(start) || len(header.Settings) != 0 {
+		t.Errorf("unexpected header %+v", header)
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"empty":  {},
+		"magic":  []byte("NOPE\x01\x01\x00"),
+		"newer":  append([]byte(magic), Version+1, 1, 0),
+		"header": []byte(magic + "\x01"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(path); err != ErrInvalidFile {
+			t.Errorf("%s: expected ErrInvalidFile, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package room
+
+import (
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// chatHistory ring buffer of the most recent chat and system messages in a room
+type chatHistory struct {
+	entries []*pb.RoomChatEntry
+	// Where the next entry goes
+	next int
+	full bool
+}
+
+func newChatHistory(size int) *chatHistory {
+	if size < 0 {
+		size = 0
+	}
+	return &chatHistory{
+		entries: make([]*pb.RoomChatEntry, size),
+	}
+}
+
+// recent the history, oldest first
+func (history *chatHistory) recent() []*pb.RoomChatEntry {
+	if !history.full {
+		return append([]*pb.RoomChatEntry(nil), history.entries[:history.next]...)
+	}
+	return append(append([]*pb.RoomChatEntry(nil), history.entries[history.next:]...), history.entries[:history.next]...)
+}
+
+// add an entry, overwriting the oldest once full
+func (history *chatHistory) add(entry *pb.RoomChatEntry) {
+	if len(history.entries) == 0 {
+		return
+	}
+
+	history.entries[history.next] = entry
+	history.next = (history.next + 1) % len(history.entries)
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+// remember add a chat or system message about the user to the room's history
+func (room *Room) remember(kind pb.RoomChatEntry_Kind, userID rose.UserID, message string) {
+	room.history.add(&pb.RoomChatEntry{
+		Kind:      kind,
+		SenderId:  uint64(userID),
+		Message:   message,
+		Timestamp: room.now().UnixNano() / 1e6,
+	})
+}
+
+// sendChatHistory catch a newcomer up on what was said before they joined
+func (room *Room) sendChatHistory(user *client.User) {
+	response := &pb.RoomChatHistoryResponse{
+		Id:      uint64(room.ID),
+		Entries: room.history.recent(),
+	}
+
+	room.send(user, pb.MessageType_RoomChatHistory, response)
+}
@@ -0,0 +1,37 @@
+package room
+
+import (
+	"testing"
+
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+func TestChatHistory(t *testing.T) {
+	history := newChatHistory(3)
+	for i := 0; i < 5; i++ {
+		history.add(&pb.RoomChatEntry{Timestamp: int64(i)})
+
+		recent := history.recent()
+		size := i + 1
+		if size > 3 {
+			size = 3
+		}
+		if len(recent) != size {
+			t.Fatalf("after %d entries: expected %d recent entries, got %d", i+1, size, len(recent))
+		}
+		// Oldest first
+		for j, entry := range recent {
+			if want := int64(i + 1 - size + j); entry.Timestamp != want {
+				t.Errorf("after %d entries: expected entry %d to be %d, got %d", i+1, j, want, entry.Timestamp)
+			}
+		}
+	}
+}
+
+func TestChatHistoryDisabled(t *testing.T) {
+	history := newChatHistory(0)
+	history.add(&pb.RoomChatEntry{Message: "hello"})
+	if recent := history.recent(); len(recent) != 0 {
+		t.Errorf("expected no history, got %d entries", len(recent))
+	}
+}
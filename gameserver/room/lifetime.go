@@ -0,0 +1,153 @@
+package room
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// checkLifetime close the room once it has been empty or alive for too long
+func (room *Room) checkLifetime(now time.Time) {
+	if room.closing {
+		return
+	}
+
+	// The node is going away
+	if !room.shutdownAt.IsZero() && now.After(room.shutdownAt) {
+		room.log.Infof("Room reached the shutdown deadline, closing")
+		room.Close(pb.RoomCloseReason_Shutdown)
+		return
+	}
+
+	cfg := room.settings
+
+	// Nobody joined, or everybody left. Spectators alone don't keep a room going, but they get their own reason
+	emptyTTL := time.Duration(cfg.EmptyRoomTTL) * time.Second
+	if emptyTTL > 0 && len(room.members) == 0 && now.Sub(room.emptySince) > emptyTTL {
+		reason := pb.RoomCloseReason_Abandoned
+		if len(room.spectators) > 0 {
+			reason = pb.RoomCloseReason_NoPlayers
+		}
+		room.log.Infof("Room has had no players for %s, closing", emptyTTL)
+		room.Close(reason)
+		return
+	}
+
+	// Rooms can't live forever
+	maxLifetime := time.Duration(cfg.MaxRoomLifetime) * time.Second
+	if maxLifetime > 0 && now.Sub(room.created) > maxLifetime {
+		room.log.Infof("Room reached its maximum lifetime of %s, closing", maxLifetime)
+		room.Close(pb.RoomCloseReason_Expired)
+	}
+}
+
+// Close tear down the room, the reason is passed on to the master and everyone still in the room
+func (room *Room) Close(reason pb.RoomCloseReason) {
+	if room.closing {
+		return
+	}
+	room.closing = true
+	room.closeReason = reason
+	room.recordEvent(pb.MessageType_CloseRoom, &pb.CloseRoomRequest{
+		Id:     uint64(room.ID),
+		Reason: reason,
+	})
+	room.broadcast(pb.MessageType_RoomClosed, &pb.RoomClosedNotice{
+		Id:     uint64(room.ID),
+		Reason: reason,
+	})
+
+	// Played back rooms have nobody to remove
+	if room.playback {
+		return
+	}
+
+	// Take a copy of the members, the room's own state is off limits once we leave the room loop
+	members := make([]*client.User, 0, len(room.members)+len(room.spectators))
+	for _, member := range room.members {
+		members = append(members, member)
+	}
+	for _, spectator := range room.spectators {
+		members = append(members, spectator)
+	}
+
+	// Leaving and removing goes through the lobby, which calls back into the room. Do it outside of the room loop
+	go func() {
+		for _, member := range members {
+			if err := member.LeaveRoom(); err != nil {
+				room.log.Warningf("Unable to remove user %d from closing room", member.ID)
+			}
+		}
+
+		// rose can't drop a room from its lobby, so the room winds itself down and stays behind empty
+		Do(room.ID, func(room *Room) {
+			room.finish()
+		})
+	}()
+}
+
+// finish tell the master the room is gone and let go of everything the room holds, only the first call counts
+func (room *Room) finish() {
+	if room.finished {
+		return
+	}
+	room.finished = true
+
+	room.updateMasterInfo(context.Background(), true)
+	room.stopRecording()
+	unregister(room)
+}
+
+// turnAway tell a user that joined a closing room that it's gone, and take them out again
+func (room *Room) turnAway(user *client.User) {
+	room.deliver(user, pb.MessageType_RoomClosed, &pb.RoomClosedNotice{
+		Id:     uint64(room.ID),
+		Reason: room.closeReason,
+	})
+
+	// Leaving goes through the lobby, which calls back into the room
+	go func() {
+		if err := user.LeaveRoom(); err != nil {
+			room.log.Warningf("Unable to remove user %d from closed room", user.ID)
+		}
+	}()
+}
+
+// shutdownGrace time rooms get after the deadline to leave and let the master know
+const shutdownGrace = 5 * time.Second
+
+// beginShutdown warn everyone the node is going away. Rooms that aren't playing close right away,
+// matches get until the deadline to finish
+func (room *Room) beginShutdown(deadline time.Time) {
+	room.shutdownAt = deadline
+	notice := &pb.ShutdownNotice{
+		Deadline: deadline.UnixNano() / 1e6,
+	}
+	room.recordEvent(pb.MessageType_ServerShutdown, notice)
+	room.broadcast(pb.MessageType_ServerShutdown, notice)
+
+	if room.state != StatePlaying {
+		room.Close(pb.RoomCloseReason_Shutdown)
+	}
+}
+
+// Shutdown close every room on this node, letting matches in progress finish until the timeout.
+// Returns once all rooms are gone, or they took too long.
+func Shutdown(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	count := DoAll(func(room *Room) {
+		room.beginShutdown(deadline)
+	})
+	log.Noticef("Shutting down %d rooms", count)
+
+	grace := deadline.Add(shutdownGrace)
+	for time.Now().Before(grace) && Count() > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if remaining := Count(); remaining > 0 {
+		log.Warningf("%d rooms did not close in time", remaining)
+	}
+}
@@ -0,0 +1,128 @@
+package room
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// defaultMode the game mode of matches started without one
+const defaultMode = "default"
+
+func handleStartMatch(room *Room, user *client.User, messageType pb.MessageType, message []byte) error {
+	input := &pb.StartMatchRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	// Only the owner decides when everyone is ready
+	if user.ID != room.owner {
+		room.log.Warningf("User %d tried to start the match without owning the room", user.ID)
+		return nil
+	}
+	if room.state != StateWaiting || room.closing {
+		room.log.Warningf("User %d tried to start the match while it can't start", user.ID)
+		return nil
+	}
+
+	room.matchMode = input.Mode
+	if room.matchMode == "" {
+		room.matchMode = defaultMode
+	}
+
+	// Everyone here now plays the match until it ends, whether they stay or not
+	room.matchPlayers = make([]rose.UserID, 0, len(room.members))
+	for id := range room.members {
+		room.matchPlayers = append(room.matchPlayers, id)
+	}
+	sort.Slice(room.matchPlayers, func(i, j int) bool {
+		return room.matchPlayers[i] < room.matchPlayers[j]
+	})
+	room.leavers = make(map[rose.UserID]bool)
+
+	room.SetState(StatePlaying)
+	room.log.Infof("Match started in mode %s with %d players", room.matchMode, len(room.members))
+
+	room.broadcast(pb.MessageType_StartMatch, &pb.StartMatchRequest{Mode: room.matchMode})
+	return nil
+}
+
+func handleEndMatch(room *Room, user *client.User, messageType pb.MessageType, message []byte) error {
+	input := &pb.EndMatchRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	// The owner's client runs the game, so it reports how it ended
+	if user.ID != room.owner {
+		room.log.Warningf("User %d tried to end the match without owning the room", user.ID)
+		return nil
+	}
+	if room.state != StatePlaying {
+		room.log.Warningf("User %d tried to end a match that isn't being played", user.ID)
+		return nil
+	}
+
+	room.endMatch(room.matchParticipants(input.Participants), input.Stats)
+	return nil
+}
+
+// matchParticipants the players of the match, with the results reported for them.
+// Only the players that were here when the match started can have results. Those that left before it ended,
+// or that the owner didn't report, placed last.
+func (room *Room) matchParticipants(reported []*pb.MatchParticipant) []*pb.MatchParticipant {
+	playing := make(map[rose.UserID]bool, len(room.matchPlayers))
+	for _, id := range room.matchPlayers {
+		playing[id] = true
+	}
+
+	participants := make([]*pb.MatchParticipant, 0, len(room.matchPlayers))
+	seen := make(map[rose.UserID]bool, len(room.matchPlayers))
+	last := int32(len(room.matchPlayers))
+	for _, participant := range reported {
+		id := rose.UserID(participant.UserId)
+		if !playing[id] || seen[id] {
+			room.log.Warningf("Dropped match result for user %d, who isn't playing", id)
+			continue
+		}
+		seen[id] = true
+
+		// Leaving is losing, whatever the owner says
+		if room.leavers[id] {
+			continue
+		}
+		participants = append(participants, participant)
+		if participant.Placement >= last {
+			last = participant.Placement + 1
+		}
+	}
+
+	// In the order they were listed at the start, so replays send the same results in the same order
+	for _, id := range room.matchPlayers {
+		if seen[id] && !room.leavers[id] {
+			continue
+		}
+		participants = append(participants, &pb.MatchParticipant{
+			UserId:    uint64(id),
+			Placement: last,
+		})
+	}
+
+	return participants
+}
+
+// endMatch the game is over, tell everyone how it went and report it to the master.
+// A room plays a single match, it closes once the result is in.
+func (room *Room) endMatch(participants []*pb.MatchParticipant, stats map[string]string) {
+	room.broadcast(pb.MessageType_EndMatch, &pb.EndMatchRequest{
+		Participants: participants,
+		Stats:        stats,
+	})
+	room.ReportMatchResult(room.matchMode, participants, stats)
+	room.Close(pb.RoomCloseReason_Finished)
+}
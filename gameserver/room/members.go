@@ -0,0 +1,72 @@
+package room
+
+import (
+	"sort"
+
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// memberInfo describe a member of the room to other users
+func memberInfo(user *client.User) *pb.RoomMember {
+	return &pb.RoomMember{
+		UserId:      uint64(user.ID),
+		DisplayName: user.Profile.DisplayName,
+		AvatarId:    int32(user.Profile.AvatarID),
+		Level:       int32(user.Profile.Level),
+	}
+}
+
+// sendMemberList send the full list of members to the given user
+func (room *Room) sendMemberList(user *client.User) {
+	response := &pb.RoomMemberList{
+		Id:      uint64(room.ID),
+		Members: make([]*pb.RoomMember, 0, len(room.members)),
+		Owner:   uint64(room.owner),
+	}
+
+	for _, member := range room.members {
+		response.Members = append(response.Members, memberInfo(member))
+	}
+
+	// Keep the order stable, replays depend on it
+	sort.Slice(response.Members, func(i, j int) bool {
+		return response.Members[i].UserId < response.Members[j].UserId
+	})
+
+	room.send(user, pb.MessageType_RoomMembers, response)
+}
+
+// announceMember tell all other members that the given user joined or left
+func (room *Room) announceMember(user *client.User, messageType pb.MessageType) {
+	info := memberInfo(user)
+
+	for id, member := range room.members {
+		if id == user.ID {
+			continue
+		}
+		room.send(member, messageType, info)
+	}
+}
+
+// passOwnership make the member with the lowest id the owner, so replays pick the same one, and let everyone know.
+// Without members the next one to join becomes the owner.
+func (room *Room) passOwnership() {
+	room.owner = 0
+	for id := range room.members {
+		if room.owner == 0 || id < room.owner {
+			room.owner = id
+		}
+	}
+	if room.owner == 0 {
+		return
+	}
+
+	room.log.Infof("User %d owns the room now", room.owner)
+	for _, member := range room.members {
+		room.sendMemberList(member)
+	}
+	for _, spectator := range room.spectators {
+		room.sendMemberList(spectator)
+	}
+}
@@ -1,15 +1,23 @@
 package room
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/gameserver/client"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/audit"
 )
 
 // SetupMessageHandlers handles incoming messages from the client
 func SetupMessageHandlers() {
 	messageMap[pb.MessageType_Chat] = handleChatMessage
+	messageMap[pb.MessageType_Mute] = handleMuteRequest
+	messageMap[pb.MessageType_StartMatch] = handleStartMatch
+	messageMap[pb.MessageType_EndMatch] = handleEndMatch
 }
 
 func handleChatMessage(room *Room, user *client.User, messageType pb.MessageType, message []byte) error {
@@ -20,11 +28,67 @@ func handleChatMessage(room *Room, user *client.User, messageType pb.MessageType
 		return err
 	}
 
+	// Apply the chat rules, telling the sender why their message didn't go through
+	if ok, reason := room.moderateChat(user, input, room.now()); !ok {
+		room.send(user, pb.MessageType_ChatRejected, &pb.ChatRejectedResponse{Reason: reason})
+		return nil
+	}
+
+	// Never trust the client with who said it
+	input.SenderId = uint64(user.ID)
+
 	// Debug print the chat message
-	log.Debug(input.Message)
+	room.log.With(shared.Fields{"user_id": user.ID}).Debugf("%s", input.Message)
+
+	// Keep it around for users that join later
+	room.remember(pb.RoomChatEntry_Chat, user.ID, input.Message)
 
 	// Send message to all connected users in the room
-	room.Broadcast(rose.MessageType(pb.MessageType_Chat), input)
+	room.broadcast(pb.MessageType_Chat, input)
+
+	return nil
+}
+
+func handleMuteRequest(room *Room, user *client.User, messageType pb.MessageType, message []byte) error {
+	input := &pb.MuteRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	// Only the room owner and admins can mute
+	if !room.isModerator(user) {
+		room.log.Warningf("User %d tried to mute %d without permission", user.ID, input.UserId)
+		audit.RecordLimited("user.mute_denied", audit.User(uint64(user.ID)), audit.User(input.UserId), map[string]string{
+			"room": strconv.FormatUint(uint64(room.ID), 10),
+		})
+		return nil
+	}
+
+	// Clamp in seconds, so huge durations can't overflow into short or negative ones
+	duration := input.Duration
+	if max := room.settings.MaxMute; duration > max {
+		duration = max
+	}
+
+	until := room.mute(rose.UserID(input.UserId), time.Duration(duration)*time.Second)
+	room.log.Infof("User %d muted %d for %ds", user.ID, input.UserId, duration)
+	audit.Record("user.mute", audit.User(uint64(user.ID)), audit.User(input.UserId), map[string]string{
+		"room":     strconv.FormatUint(uint64(room.ID), 10),
+		"duration": strconv.FormatInt(duration, 10),
+	})
+
+	// Let everyone know, an empty until means unmuted
+	notice := &pb.MuteStatus{
+		UserId: input.UserId,
+	}
+	if !until.IsZero() {
+		notice.Until = until.UnixNano() / 1e6
+		room.remember(pb.RoomChatEntry_Muted, rose.UserID(input.UserId), "")
+	} else {
+		room.remember(pb.RoomChatEntry_Unmuted, rose.UserID(input.UserId), "")
+	}
+	room.broadcast(pb.MessageType_MuteNotice, notice)
 
 	return nil
 }
@@ -0,0 +1,166 @@
+package room
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// chatBucket token bucket limiting how fast a user can chat
+type chatBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow take a token if one is available, refilling at rate tokens per second up to burst
+func (bucket *chatBucket) allow(now time.Time, rate float64, burst int) bool {
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+	if bucket.tokens > float64(burst) {
+		bucket.tokens = float64(burst)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// wordFilter matches any of a list of whole words, case insensitive
+type wordFilter struct {
+	pattern *regexp.Regexp
+}
+
+// newWordFilter build a filter for the given words.
+// Go's \b only knows ASCII word characters, so words are delimited by anything that isn't a letter or digit in
+// any script instead. The pattern only matches the boundary in front, the one after is checked by matches, so
+// two filtered words separated by a single character are both found.
+func newWordFilter(words []string) *wordFilter {
+	// Longest first, so a word doesn't lose to a shorter one it starts with
+	sorted := append([]string(nil), words...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	quoted := make([]string, 0, len(sorted))
+	for _, word := range sorted {
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+
+	filter := &wordFilter{}
+	if len(quoted) > 0 {
+		filter.pattern = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(` + strings.Join(quoted, "|") + `)`)
+	}
+	return filter
+}
+
+// matches the start and end of every filtered word in the text
+func (f *wordFilter) matches(text string) [][2]int {
+	if f.pattern == nil {
+		return nil
+	}
+
+	var found [][2]int
+	for _, loc := range f.pattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[2], loc[3]
+		if next, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && (unicode.IsLetter(next) || unicode.IsNumber(next)) {
+			continue
+		}
+		found = append(found, [2]int{start, end})
+	}
+	return found
+}
+
+// Match returns true if the text contains a filtered word
+func (f *wordFilter) Match(text string) bool {
+	return len(f.matches(text)) > 0
+}
+
+// Mask replace every filtered word with asterisks
+func (f *wordFilter) Mask(text string) string {
+	found := f.matches(text)
+	if len(found) == 0 {
+		return text
+	}
+
+	var masked strings.Builder
+	last := 0
+	for _, match := range found {
+		masked.WriteString(text[last:match[0]])
+		masked.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[match[0]:match[1]])))
+		last = match[1]
+	}
+	masked.WriteString(text[last:])
+	return masked.String()
+}
+
+// moderateChat apply the chat rules to a message from the user, masking it if needed.
+// Returns false with a reason if the message may not be sent.
+func (room *Room) moderateChat(user *client.User, message *pb.ChatMessage, now time.Time) (bool, pb.ChatRejectedResponse_Reason) {
+	cfg := room.settings
+
+	if until, ok := room.mutes[user.ID]; ok {
+		if now.Before(until) {
+			return false, pb.ChatRejectedResponse_Muted
+		}
+		delete(room.mutes, user.ID)
+	}
+
+	if cfg.ChatMaxLength > 0 && utf8.RuneCountInString(message.Message) > cfg.ChatMaxLength {
+		return false, pb.ChatRejectedResponse_TooLong
+	}
+
+	if cfg.ChatRate > 0 {
+		bucket, ok := room.chatBuckets[user.ID]
+		if !ok {
+			bucket = &chatBucket{tokens: float64(cfg.ChatBurst), last: now}
+			room.chatBuckets[user.ID] = bucket
+		}
+		if !bucket.allow(now, cfg.ChatRate, cfg.ChatBurst) {
+			return false, pb.ChatRejectedResponse_RateLimited
+		}
+	}
+
+	if room.filter.Match(message.Message) {
+		if cfg.ChatFilterMode == "reject" {
+			return false, pb.ChatRejectedResponse_Filtered
+		}
+		message.Message = room.filter.Mask(message.Message)
+	}
+
+	return true, 0
+}
+
+// isModerator returns true if the user may mute others in this room
+func (room *Room) isModerator(user *client.User) bool {
+	if user.ID == room.owner {
+		return true
+	}
+
+	for _, admin := range room.settings.Admins {
+		if rose.UserID(admin) == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// mute stop the user from chatting for the given duration, zero or less unmutes
+func (room *Room) mute(userID rose.UserID, duration time.Duration) time.Time {
+	if duration <= 0 {
+		delete(room.mutes, userID)
+		return time.Time{}
+	}
+
+	until := room.now().Add(duration)
+	room.mutes[userID] = until
+	return until
+}
@@ -0,0 +1,46 @@
+package room
+
+import (
+	"testing"
+)
+
+func TestWordFilter(t *testing.T) {
+	filter := newWordFilter([]string{"bad", "badge", "schlecht", "плохо"})
+
+	tests := []struct {
+		text   string
+		masked string
+	}{
+		{"nothing here", "nothing here"},
+		{"bad", "***"},
+		{"BAD bad,bad", "*** ***,***"},
+		{"badger", "badger"},
+		{"a badge", "a *****"},
+		{"schlechter", "schlechter"},
+		{"sehr schlecht!", "sehr ********!"},
+		// Letters outside of ASCII are part of the word too
+		{"überbad", "überbad"},
+		{"badé", "badé"},
+		{"очень плохо", "очень *****"},
+		{"неплохо", "неплохо"},
+	}
+
+	for _, test := range tests {
+		if masked := filter.Mask(test.text); masked != test.masked {
+			t.Errorf("Mask(%q): expected %q, got %q", test.text, test.masked, masked)
+		}
+		if matched := filter.Match(test.text); matched != (test.masked != test.text) {
+			t.Errorf("Match(%q): expected %v, got %v", test.text, !matched, matched)
+		}
+	}
+}
+
+func TestEmptyWordFilter(t *testing.T) {
+	filter := newWordFilter([]string{""})
+	if filter.Match("anything") {
+		t.Errorf("an empty filter shouldn't match")
+	}
+	if masked := filter.Mask("anything"); masked != "anything" {
+		t.Errorf("an empty filter shouldn't mask, got %q", masked)
+	}
+}
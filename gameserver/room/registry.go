@@ -0,0 +1,107 @@
+package room
+
+import (
+	"sync"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/gameserver/metrics"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+var (
+	// rooms every live room on this node, by id
+	rooms     = make(map[rose.RoomID]*Room)
+	roomsLock sync.RWMutex
+)
+
+// register make the room reachable through Do
+func register(room *Room) {
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+
+	rooms[room.ID] = room
+	metrics.Rooms.WithLabelValues(stateName(room.state)).Inc()
+}
+
+// unregister forget about the room
+func unregister(room *Room) {
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+
+	if rooms[room.ID] == room {
+		delete(rooms, room.ID)
+		metrics.Rooms.WithLabelValues(stateName(room.state)).Dec()
+	}
+}
+
+// Count the number of rooms on this node
+func Count() int {
+	roomsLock.RLock()
+	defer roomsLock.RUnlock()
+
+	return len(rooms)
+}
+
+// Do run the action on the room with the given id from within the room's own loop, on its next tick.
+// Returns false if there is no such room.
+func Do(id rose.RoomID, action func(*Room)) bool {
+	roomsLock.RLock()
+	room, ok := rooms[id]
+	roomsLock.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	room.actionsLock.Lock()
+	room.actions = append(room.actions, action)
+	room.actionsLock.Unlock()
+
+	return true
+}
+
+// runActions run everything queued with Do
+func (room *Room) runActions() {
+	room.actionsLock.Lock()
+	actions := room.actions
+	room.actions = nil
+	room.actionsLock.Unlock()
+
+	for _, action := range actions {
+		action(room)
+	}
+}
+
+// Kick remove a user from the room and disconnect them, returns false if they're not here
+func (room *Room) Kick(id rose.UserID, reason string) bool {
+	user, ok := room.members[id]
+	if !ok {
+		user, ok = room.spectators[id]
+	}
+	if !ok {
+		return false
+	}
+
+	room.recordEvent(pb.MessageType_KickUser, &pb.KickUserRequest{
+		RoomId: uint64(room.ID),
+		UserId: uint64(id),
+		Reason: reason,
+	})
+	room.send(user, pb.MessageType_Kicked, &pb.KickNotice{
+		Id:     uint64(room.ID),
+		Reason: reason,
+	})
+	room.remember(pb.RoomChatEntry_Kicked, id, reason)
+	room.log.Noticef("Kicked user %d: %s", id, reason)
+
+	// Disconnecting removes them from the room, which has to happen outside of the room loop
+	if room.playback {
+		return true
+	}
+	go func(user *client.User) {
+		user.Disconnect()
+	}(user)
+
+	return true
+}
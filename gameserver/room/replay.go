@@ -0,0 +1,253 @@
+package room
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/gameserver/config"
+	"github.com/zeroZshadow/rose-example/gameserver/replay"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// startRecording record the room to the configured replay directory, if any
+func (room *Room) startRecording() {
+	dir := config.Get().ReplayDir
+	if dir == "" {
+		return
+	}
+
+	// Playback runs with the settings the room had, not with whatever is configured then
+	settings, err := json.Marshal(room.settings)
+	if err != nil {
+		room.log.Errorf("Unable to record room settings: %s", err)
+		return
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d.replay", room.ID))
+	recorder, err := replay.Create(path, room.ID, room.created, settings)
+	if err != nil {
+		room.log.Errorf("Unable to record room: %s", err)
+		return
+	}
+
+	room.recorder = recorder
+	room.log.Debugf("Recording room to %s", path)
+}
+
+// stopRecording finish the replay file
+func (room *Room) stopRecording() {
+	if room.recorder == nil {
+		return
+	}
+
+	if err := room.recorder.Close(); err != nil {
+		room.log.Errorf("Failed to finish replay: %s", err)
+	}
+	room.recorder = nil
+}
+
+// now the current time, or the recorded time when playing back a replay
+func (room *Room) now() time.Time {
+	if room.playback {
+		return room.playbackNow
+	}
+	return time.Now()
+}
+
+// recordRaw add an already marshaled message to the replay
+func (room *Room) recordRaw(direction replay.Direction, userID rose.UserID, messageType pb.MessageType, payload []byte) {
+	if room.recorder == nil {
+		return
+	}
+
+	err := room.recorder.Write(room.tick, room.now(), direction, userID, uint64(messageType), payload)
+	if err != nil {
+		// A broken replay shouldn't take the match down with it
+		room.log.Errorf("Failed to record room, recording stopped: %s", err)
+		room.stopRecording()
+	}
+}
+
+// record add a message to the replay
+func (room *Room) record(direction replay.Direction, userID rose.UserID, messageType pb.MessageType, message proto.Message) {
+	if room.recorder == nil {
+		return
+	}
+
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		room.log.Errorf("Unable to record %v: %s", messageType, err)
+		return
+	}
+	room.recordRaw(direction, userID, messageType, payload)
+}
+
+// recordEvent add something that happened to the room to the replay, so playback can make it happen again
+func (room *Room) recordEvent(messageType pb.MessageType, message proto.Message) {
+	room.record(replay.Event, 0, messageType, message)
+}
+
+// recordJoin add a user joining the room to the replay
+func (room *Room) recordJoin(user *client.User) {
+	if room.recorder == nil {
+		return
+	}
+
+	payload, err := json.Marshal(replay.JoinInfo{
+		Profile:   user.Profile,
+		Spectator: user.Spectator,
+	})
+	if err != nil {
+		room.log.Errorf("Unable to record user %d joining: %s", user.ID, err)
+		return
+	}
+	room.recordRaw(replay.Join, user.ID, 0, payload)
+}
+
+// send a message to a single user, all messages from the room should go through here or broadcast
+func (room *Room) send(user *client.User, messageType pb.MessageType, message proto.Message) {
+	room.record(replay.Outbound, user.ID, messageType, message)
+	room.messagesOut.add(room.now())
+	room.deliver(user, messageType, message)
+}
+
+// broadcast a message to everyone in the room
+func (room *Room) broadcast(messageType pb.MessageType, message proto.Message) {
+	room.record(replay.Outbound, 0, messageType, message)
+	room.messagesOut.add(room.now())
+
+	// Nobody is connected to a room that is being played back
+	if room.playback {
+		return
+	}
+	room.Broadcast(rose.MessageType(messageType), message)
+}
+
+// deliver send a message without recording it
+func (room *Room) deliver(user *client.User, messageType pb.MessageType, message proto.Message) {
+	if room.playback {
+		return
+	}
+	user.SendMessage(rose.MessageType(messageType), message)
+}
+
+// Playback re-run a recorded room from the replay. Everything the room sends is recorded to out, if given,
+// so it can be compared against the original. Returns the room as it was at the end of the replay.
+// Replays from before the settings were recorded are played back with the default room type and the config in use.
+func Playback(reader *replay.Reader, out *replay.Writer) (*Room, error) {
+	header := reader.Header()
+
+	settings := newSettings(DefaultType())
+	if len(header.Settings) > 0 {
+		settings = Settings{}
+		if err := json.Unmarshal(header.Settings, &settings); err != nil {
+			return nil, fmt.Errorf("replay: invalid room settings: %s", err)
+		}
+	}
+
+	room := newRoom(header.RoomID, settings)
+	room.playback = true
+	room.playbackNow = header.Start
+	room.created = header.Start
+	room.emptySince = header.Start
+	room.recorder = out
+
+	// Stand-ins for the users that were in the room
+	users := make(map[rose.UserID]*client.User)
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return room, nil
+		}
+		if err != nil {
+			return room, err
+		}
+
+		// Catch the room up to the moment the record happened
+		room.playbackNow = header.Start.Add(record.Offset)
+		for room.tick < record.Tick {
+			room.step()
+		}
+
+		switch record.Direction {
+		case replay.Join:
+			info := replay.JoinInfo{}
+			if err := json.Unmarshal(record.Payload, &info); err != nil {
+				return room, err
+			}
+
+			user := &client.User{
+				UserBase:  &rose.UserBase{ID: record.UserID},
+				Profile:   info.Profile,
+				Spectator: info.Spectator,
+			}
+			users[record.UserID] = user
+			room.AddUser(user)
+
+		case replay.Leave:
+			if user, ok := users[record.UserID]; ok {
+				room.RemoveUser(user)
+				delete(users, record.UserID)
+			}
+
+		case replay.Inbound:
+			user, ok := users[record.UserID]
+			if !ok {
+				return room, fmt.Errorf("replay: message from user %d who is not in the room", record.UserID)
+			}
+			room.HandleMessage(user, rose.MessageType(record.MessageType), record.Payload)
+
+		case replay.Event:
+			if err := room.playEvent(record); err != nil {
+				return room, err
+			}
+
+		case replay.Outbound:
+			// This is what the room should send again by itself
+		}
+	}
+}
+
+// playEvent make a recorded event happen again
+func (room *Room) playEvent(record *replay.Record) error {
+	switch messageType := pb.MessageType(record.MessageType); messageType {
+	case pb.MessageType_CloseRoom:
+		input := &pb.CloseRoomRequest{}
+		if err := proto.Unmarshal(record.Payload, input); err != nil {
+			return err
+		}
+		room.Close(input.Reason)
+
+	case pb.MessageType_KickUser:
+		input := &pb.KickUserRequest{}
+		if err := proto.Unmarshal(record.Payload, input); err != nil {
+			return err
+		}
+		room.Kick(rose.UserID(input.UserId), input.Reason)
+
+	case pb.MessageType_SystemMessage:
+		input := &pb.SystemNotice{}
+		if err := proto.Unmarshal(record.Payload, input); err != nil {
+			return err
+		}
+		room.Announce(input.Message)
+
+	case pb.MessageType_ServerShutdown:
+		input := &pb.ShutdownNotice{}
+		if err := proto.Unmarshal(record.Payload, input); err != nil {
+			return err
+		}
+		room.beginShutdown(time.Unix(0, input.Deadline*int64(time.Millisecond)))
+
+	default:
+		return fmt.Errorf("replay: unknown event %v", messageType)
+	}
+	return nil
+}
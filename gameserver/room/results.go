@@ -0,0 +1,32 @@
+package room
+
+import (
+	"time"
+
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// ReportMatchResult send the results of the match played in this room to the master
+func (room *Room) ReportMatchResult(mode string, participants []*pb.MatchParticipant, stats map[string]string) {
+	// Matches last from the moment the room started playing
+	started := room.matchStarted
+	if started.IsZero() {
+		started = room.created
+	}
+
+	result := &pb.MatchResultRequest{
+		RoomId:       uint64(room.ID),
+		Mode:         mode,
+		Duration:     int64(room.now().Sub(started) / time.Millisecond),
+		Participants: participants,
+		Stats:        stats,
+	}
+
+	// Played back matches were reported when they were played
+	if room.playback {
+		return
+	}
+	if !sendToMaster(pb.MessageType_MatchResult, result) {
+		room.log.Errorf("Not connected to master, lost the match result")
+	}
+}
@@ -1,12 +1,19 @@
 package room
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/gameserver/client"
+	"github.com/zeroZshadow/rose-example/gameserver/config"
+	"github.com/zeroZshadow/rose-example/gameserver/metrics"
+	"github.com/zeroZshadow/rose-example/gameserver/replay"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
 )
 
 type messageHandler func(*Room, *client.User, pb.MessageType, []byte) error
@@ -14,23 +21,178 @@ type messageHandler func(*Room, *client.User, pb.MessageType, []byte) error
 var (
 	// MessageMap Map of messageType handlers
 	messageMap = make(map[pb.MessageType]messageHandler)
-	log        = logging.MustGetLogger("global")
-	tickrate   = time.Second / 60
+	log        = shared.NewLogger("room", nil)
+	tracer     = tracing.Tracer("gameserver/room")
 )
 
+const defaultTickRate = 60
+
+// Room states
+const (
+	// StateWaiting the room is waiting for players
+	StateWaiting = iota
+	// StatePlaying the room is in game
+	StatePlaying
+)
+
+// stateName name of the state, for metrics and logs
+func stateName(state int) string {
+	switch state {
+	case StateWaiting:
+		return "waiting"
+	case StatePlaying:
+		return "playing"
+	}
+	return "unknown"
+}
+
+// Type describes a kind of room, tick rates are in ticks per second
+type Type struct {
+	WaitingTickRate int `json:"waitingtickrate"`
+	PlayingTickRate int `json:"playingtickrate"`
+}
+
+// DefaultType the room type described by the global config
+func DefaultType() Type {
+	cfg := config.Get()
+	return Type{
+		WaitingTickRate: cfg.WaitingTickRate,
+		PlayingTickRate: cfg.TickRate,
+	}
+}
+
+// LookupType the room type a create request asked for, by name and with an optional playing tick rate.
+// An empty name is the default type, a tick rate of 0 keeps the type's own.
+func LookupType(name string, tickRate int) (Type, error) {
+	cfg := config.Get()
+
+	roomType := DefaultType()
+	if name != "" {
+		configured, ok := cfg.RoomTypes[name]
+		if !ok {
+			return Type{}, fmt.Errorf("unknown room type %q", name)
+		}
+		roomType = Type{
+			WaitingTickRate: configured.WaitingTickRate,
+			PlayingTickRate: configured.TickRate,
+		}
+	}
+
+	if tickRate != 0 {
+		if tickRate < 0 || tickRate > cfg.MaxTickRate {
+			return Type{}, fmt.Errorf("tick rate %d is not between 1 and %d", tickRate, cfg.MaxTickRate)
+		}
+		roomType.PlayingTickRate = tickRate
+	}
+
+	return roomType, nil
+}
+
 // Room will have to be concurrent, all functions altering the room
 // or dealing with the room will have to be private.
 // With the exception to the functions passing the events along
 type Room struct {
 	// Framework
 	*rose.RoomBase
+
+	// log tags everything with the room
+	log *shared.Logger
+
+	settings   Settings
+	filter     *wordFilter
+	inviteOnly bool
+	state      int
+	owner      rose.UserID
+	members    map[rose.UserID]*client.User
+
+	// Spectators watch without taking a player slot, state updates reach them through the queue
+	spectators     map[rose.UserID]*client.User
+	spectatorQueue []delayedMessage
+
+	// Chat
+	history     *chatHistory
+	mutes       map[rose.UserID]time.Time
+	chatBuckets map[rose.UserID]*chatBucket
+
+	// Lifetime
+	created      time.Time
+	matchStarted time.Time
+	matchMode    string
+	matchPlayers []rose.UserID        // Members when the match started, sorted
+	leavers      map[rose.UserID]bool // Players that left before the match ended
+	emptySince   time.Time
+	closing      bool
+	finished     bool
+	closeReason  pb.RoomCloseReason
+	shutdownAt   time.Time
+
+	// rose ticks the room every baseInterval, game logic only runs every tickInterval
+	baseInterval time.Duration
+	tickInterval time.Duration
+	nextTick     time.Time
+	tick         uint64
+
+	// Replays
+	recorder    *replay.Writer
+	playback    bool
+	playbackNow time.Time
+
+	// Statistics
+	tickTimer   tickTimer
+	messagesIn  rateCounter
+	messagesOut rateCounter
+
+	// Work handed to the room from outside its loop, see Do
+	actions     []func(*Room)
+	actionsLock sync.Mutex
+}
+
+// New a constructor for rooms of the given type
+func New(roomType Type, inviteOnly bool) rose.RoomConstructor {
+	return func(id rose.RoomID) rose.Room {
+		room := newRoom(id, newSettings(roomType))
+		room.inviteOnly = inviteOnly
+		room.startRecording()
+		register(room)
+		return room
+	}
+}
+
+func newRoom(id rose.RoomID, settings Settings) *Room {
+	// Let rose tick at the fastest rate this type of room will ever need
+	baseInterval := tickInterval(settings.Type.WaitingTickRate)
+	if playing := tickInterval(settings.Type.PlayingTickRate); playing < baseInterval {
+		baseInterval = playing
+	}
+
+	now := time.Now()
+	room := &Room{
+		RoomBase:     rose.NewRoomBase(id, baseInterval),
+		log:          log.With(shared.Fields{"node": config.Get().Name, "room_id": id}),
+		settings:     settings,
+		filter:       newWordFilter(settings.ChatFilter),
+		state:        StateWaiting,
+		members:      make(map[rose.UserID]*client.User),
+		spectators:   make(map[rose.UserID]*client.User),
+		history:      newChatHistory(settings.ChatHistorySize),
+		mutes:        make(map[rose.UserID]time.Time),
+		chatBuckets:  make(map[rose.UserID]*chatBucket),
+		leavers:      make(map[rose.UserID]bool),
+		created:      now,
+		emptySince:   now,
+		baseInterval: baseInterval,
+	}
+	room.SetTickRate(settings.Type.WaitingTickRate)
+
+	return room
 }
 
-// New create a new Room
-func New(id rose.RoomID) rose.Room {
-	return &Room{
-		RoomBase: rose.NewRoomBase(id, tickrate),
+// tickInterval convert a tick rate to the time between ticks
+func tickInterval(rate int) time.Duration {
+	if rate <= 0 {
+		rate = defaultTickRate
 	}
+	return time.Second / time.Duration(rate)
 }
 
 // Base Implement Room.Base
@@ -38,32 +200,120 @@ func (room *Room) Base() *rose.RoomBase {
 	return room.RoomBase
 }
 
+// SetTickRate change the number of ticks per second,
+// rates faster than the room type allows are clamped
+func (room *Room) SetTickRate(rate int) {
+	interval := tickInterval(rate)
+	if interval < room.baseInterval {
+		interval = room.baseInterval
+	}
+	room.tickInterval = interval
+}
+
+// SetState change the state of the room and switch to its tick rate
+func (room *Room) SetState(state int) {
+	if !room.playback {
+		metrics.Rooms.WithLabelValues(stateName(room.state)).Dec()
+		metrics.Rooms.WithLabelValues(stateName(state)).Inc()
+	}
+	room.state = state
+
+	switch state {
+	case StatePlaying:
+		room.matchStarted = room.now()
+		room.SetTickRate(room.settings.Type.PlayingTickRate)
+	default:
+		room.SetTickRate(room.settings.Type.WaitingTickRate)
+	}
+
+	// Tell the master server about the new state
+	room.updateMasterInfo(context.Background(), false)
+}
+
 // Tick Implement Room.Tick
 func (room *Room) Tick() {
+	now := time.Now()
+
+	// Run whatever was asked of us since the last tick
+	room.runActions()
 
+	// Closed rooms linger in rose's lobby, there's nothing left to do for them
+	if room.finished {
+		return
+	}
+
+	// Tear down rooms that outlived their welcome
+	room.checkLifetime(now)
+
+	// Spectators keep watching what's left of the stream, even if the players are gone
+	room.flushSpectatorQueue(now)
+
+	// Rooms without members do not run game logic until someone joins
+	if len(room.members) == 0 {
+		return
+	}
+
+	// Skip ticks until the room's own tick rate is due, allowing for some jitter
+	if now.Add(room.baseInterval / 2).Before(room.nextTick) {
+		return
+	}
+	room.nextTick = room.nextTick.Add(room.tickInterval)
+	if room.nextTick.Before(now) {
+		room.nextTick = now.Add(room.tickInterval)
+	}
+
+	room.step()
+
+	elapsed := time.Since(now)
+	room.tickTimer.add(elapsed)
+	metrics.TickDuration.Observe(elapsed.Seconds())
+}
+
+// step run a single tick of game logic, anything in here has to be deterministic for replays
+func (room *Room) step() {
+	room.tick++
+
+	// The example game has no state beyond the tick, but it reaches players and spectators like a real game's would
+	if room.state == StatePlaying {
+		room.BroadcastState(pb.MessageType_GameState, &pb.GameStateUpdate{Tick: room.tick})
+	}
 }
 
 // HandleMessage implements rose.Room.HandleMessage
 func (room *Room) HandleMessage(user rose.User, msgType rose.MessageType, message []byte) {
 	messageType := pb.MessageType(msgType)
+	room.recordRaw(replay.Inbound, user.Base().ID, messageType, message)
+	room.messagesIn.add(room.now())
+	room.log.With(shared.Fields{"user_id": user.Base().ID}).Debugf("Received %v", messageType)
 
 	// Handle message according to type
 	if handler, ok := messageMap[messageType]; ok {
-		// Handle packet
+		// Spectators can talk, but not play
+		if room.isSpectator(user.Base().ID) && !spectatorMessages[messageType] {
+			room.log.Warningf("Spectator %d sent %v", user.Base().ID, messageType)
+			return
+		}
+
+		// Handle packet, playback doesn't count towards the node's metrics
+		start := time.Now()
 		err := handler(room, user.(*client.User), messageType, message)
+		if !room.playback {
+			metrics.Messages.WithLabelValues("room", messageType.String()).Inc()
+			metrics.HandlerDuration.WithLabelValues("room", messageType.String()).Observe(time.Since(start).Seconds())
+		}
 		if err != nil {
-			log.Errorf("room error: %s\n%v", err, message)
+			room.log.Errorf("room error: %s\n%v", err, message)
 		}
 		return
 	}
 
-	log.Errorf("Unhandled messageType %v from %d", messageType, user.Base().ID)
+	room.log.Errorf("Unhandled messageType %v from %d", messageType, user.Base().ID)
 }
 
 // Cleanup implements rose.Room.Cleanup
 func (room *Room) Cleanup() {
-	// Inform master about the removed room
-	room.updateMasterInfo(true)
+	// Inform master about the removed room, unless it closed by itself before
+	room.finish()
 
 	// Run base destroy
 	room.RoomBase.Cleanup()
@@ -74,18 +324,60 @@ func (room *Room) AddUser(user rose.User) {
 	// Assert user to client
 	userClient, ok := user.(*client.User)
 	if !ok {
-		log.Critical("Non-client user tried to join room")
+		room.log.Criticalf("Non-client user tried to join room")
+		return
+	}
+
+	// Joining is the last step of the user's room request
+	ctx, span := tracer.Start(tracing.Extract(userClient.TraceParent), "Room.AddUser")
+	defer span.End()
+
+	// Too late, the room is going away
+	if room.closing && !room.playback {
+		room.RoomBase.AddUser(userClient)
+		room.turnAway(userClient)
 		return
 	}
 
-	// Add user to the room
-	room.RoomBase.AddUser(userClient)
+	// Add user to the room, played back rooms have nobody to talk to
+	room.recordJoin(userClient)
+	if !room.playback {
+		room.RoomBase.AddUser(userClient)
+	}
 
-	// TODO Tell other users I'm here
-	log.Debugf("A new user joined room %d", room.ID)
+	// Spectators only need to know what's going on
+	if userClient.Spectator {
+		room.spectators[userClient.ID] = userClient
+		room.sendMemberList(userClient)
+		room.sendChatHistory(userClient)
+		room.log.With(shared.Fields{"user_id": userClient.ID}).Debugf("A new spectator joined")
+
+		room.updateMasterInfo(ctx, false)
+		return
+	}
+
+	// The first user to join created the room
+	if room.owner == 0 {
+		room.owner = userClient.ID
+	}
+
+	// Resume ticking right away if the room was idle
+	if len(room.members) == 0 {
+		room.nextTick = time.Time{}
+	}
+	room.members[userClient.ID] = userClient
+
+	// Tell the newcomer who's here, and everyone else about the newcomer
+	room.sendMemberList(userClient)
+	room.announceMember(userClient, pb.MessageType_MemberJoined)
+
+	// Catch them up on the conversation, then note their arrival
+	room.sendChatHistory(userClient)
+	room.remember(pb.RoomChatEntry_Joined, userClient.ID, "")
+	room.log.With(shared.Fields{"user_id": userClient.ID}).Debugf("A new user joined")
 
 	// Tell the master server about the new user
-	room.updateMasterInfo(false)
+	room.updateMasterInfo(ctx, false)
 }
 
 // RemoveUser is overwriting RoomBase.RemoveUser
@@ -93,15 +385,55 @@ func (room *Room) RemoveUser(user rose.User) {
 	// Assert user to client
 	userClient, ok := user.(*client.User)
 	if !ok {
-		log.Critical("Non-client user tried to leave room")
+		room.log.Criticalf("Non-client user tried to leave room")
+		return
+	}
+
+	// Users turned away from a closing room were never really in it
+	_, member := room.members[userClient.ID]
+	_, spectator := room.spectators[userClient.ID]
+	if !member && !spectator {
+		if !room.playback {
+			room.RoomBase.RemoveUser(userClient)
+		}
+		return
+	}
+
+	room.recordRaw(replay.Leave, userClient.ID, 0, nil)
+	if !room.playback {
+		room.RoomBase.RemoveUser(userClient)
+	}
+
+	if _, ok := room.spectators[userClient.ID]; ok {
+		delete(room.spectators, userClient.ID)
+		delete(room.chatBuckets, userClient.ID)
+		room.log.With(shared.Fields{"user_id": userClient.ID}).Debugf("A spectator left")
+
+		room.updateMasterInfo(context.Background(), false)
 		return
 	}
 
-	room.RoomBase.RemoveUser(userClient)
+	delete(room.members, userClient.ID)
+	delete(room.chatBuckets, userClient.ID)
+	if len(room.members) == 0 {
+		room.emptySince = room.now()
+	}
+
+	// Walking out of a match counts as a loss
+	if room.state == StatePlaying {
+		room.leavers[userClient.ID] = true
+	}
+
+	// Tell other users I've left
+	room.announceMember(userClient, pb.MessageType_MemberLeft)
+	room.remember(pb.RoomChatEntry_Left, userClient.ID, "")
+	room.log.With(shared.Fields{"user_id": userClient.ID}).Debugf("A user left")
 
-	// TODO Tell other users I've left
-	log.Debugf("A user left room %d", room.ID)
+	// Somebody has to be able to start and end the match
+	if userClient.ID == room.owner {
+		room.passOwnership()
+	}
 
 	// Tell the master server that a user left
-	room.updateMasterInfo(false)
+	room.updateMasterInfo(context.Background(), false)
 }
@@ -0,0 +1,41 @@
+package room
+
+import (
+	"github.com/zeroZshadow/rose-example/gameserver/config"
+)
+
+// Settings the part of the config a room runs with. Rooms take them from the config when they are created and keep
+// them, so a reload only changes rooms created after it, and replays store them to be played back the same way.
+type Settings struct {
+	Type            Type     `json:"type"`
+	EmptyRoomTTL    int      `json:"emptyroomttl"`    // Seconds, 0 disables
+	MaxRoomLifetime int      `json:"maxroomlifetime"` // Seconds, 0 disables
+	ChatMaxLength   int      `json:"chatmaxlength"`
+	ChatRate        float64  `json:"chatrate"`
+	ChatBurst       int      `json:"chatburst"`
+	ChatFilter      []string `json:"chatfilter"`
+	ChatFilterMode  string   `json:"chatfiltermode"`
+	Admins          []uint64 `json:"admins"`
+	MaxMute         int64    `json:"maxmute"` // Seconds
+	ChatHistorySize int      `json:"chathistorysize"`
+	SpectatorDelay  int      `json:"spectatordelay"` // Milliseconds, 0 disables
+}
+
+// newSettings the settings for a room of the given type, from the config in use
+func newSettings(roomType Type) Settings {
+	cfg := config.Get()
+	return Settings{
+		Type:            roomType,
+		EmptyRoomTTL:    cfg.EmptyRoomTTL,
+		MaxRoomLifetime: cfg.MaxRoomLifetime,
+		ChatMaxLength:   cfg.ChatMaxLength,
+		ChatRate:        cfg.ChatRate,
+		ChatBurst:       cfg.ChatBurst,
+		ChatFilter:      cfg.ChatFilter,
+		ChatFilterMode:  cfg.ChatFilterMode,
+		Admins:          cfg.Admins,
+		MaxMute:         cfg.MaxMute,
+		ChatHistorySize: cfg.ChatHistorySize,
+		SpectatorDelay:  cfg.SpectatorDelay,
+	}
+}
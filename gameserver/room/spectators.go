@@ -0,0 +1,77 @@
+package room
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/gameserver/replay"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// spectatorMessages the only messages spectators may send, everything else is gameplay input
+var spectatorMessages = map[pb.MessageType]bool{
+	pb.MessageType_Chat: true,
+}
+
+// delayedMessage a state update waiting to be sent to the spectators
+type delayedMessage struct {
+	due         time.Time
+	messageType pb.MessageType
+	message     proto.Message
+}
+
+// isSpectator returns true if the user is watching rather than playing
+func (room *Room) isSpectator(id rose.UserID) bool {
+	_, ok := room.spectators[id]
+	return ok
+}
+
+// BroadcastState send a game state update to the players right away,
+// spectators get it after the configured delay so they can't be used to scout
+func (room *Room) BroadcastState(messageType pb.MessageType, message proto.Message) {
+	room.record(replay.Outbound, 0, messageType, message)
+	room.messagesOut.add(room.now())
+	for _, member := range room.members {
+		room.deliver(member, messageType, message)
+	}
+
+	if len(room.spectators) == 0 {
+		return
+	}
+
+	delay := time.Duration(room.settings.SpectatorDelay) * time.Millisecond
+	if delay <= 0 {
+		room.sendToSpectators(messageType, message)
+		return
+	}
+
+	// Game logic is free to reuse the message, keep our own copy
+	room.spectatorQueue = append(room.spectatorQueue, delayedMessage{
+		due:         room.now().Add(delay),
+		messageType: messageType,
+		message:     proto.Clone(message),
+	})
+}
+
+// flushSpectatorQueue send the delayed state updates that are due
+func (room *Room) flushSpectatorQueue(now time.Time) {
+	sent := 0
+	for _, delayed := range room.spectatorQueue {
+		if delayed.due.After(now) {
+			break
+		}
+		room.sendToSpectators(delayed.messageType, delayed.message)
+		sent++
+	}
+
+	if sent > 0 {
+		room.spectatorQueue = append(room.spectatorQueue[:0], room.spectatorQueue[sent:]...)
+	}
+}
+
+func (room *Room) sendToSpectators(messageType pb.MessageType, message proto.Message) {
+	for _, spectator := range room.spectators {
+		room.deliver(spectator, messageType, message)
+	}
+}
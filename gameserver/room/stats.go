@@ -0,0 +1,204 @@
+package room
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+const (
+	// rateWindow how long message rates are averaged over
+	rateWindow = 10 * time.Second
+	// inspectTimeout how long to wait for a room to describe itself
+	inspectTimeout = 2 * time.Second
+)
+
+// rateCounter counts events, and their rate over the last complete window
+type rateCounter struct {
+	total   uint64
+	window  uint64
+	started time.Time
+	rate    float64
+}
+
+func (counter *rateCounter) add(now time.Time) {
+	counter.roll(now)
+	counter.total++
+	counter.window++
+}
+
+// roll start a new window once the current one is complete
+func (counter *rateCounter) roll(now time.Time) {
+	if counter.started.IsZero() {
+		counter.started = now
+		return
+	}
+
+	elapsed := now.Sub(counter.started)
+	if elapsed >= rateWindow {
+		counter.rate = float64(counter.window) / elapsed.Seconds()
+		counter.window = 0
+		counter.started = now
+	}
+}
+
+// tickTimer keeps track of how long game logic takes to run
+type tickTimer struct {
+	last    time.Duration
+	average time.Duration
+	max     time.Duration
+}
+
+func (timer *tickTimer) add(duration time.Duration) {
+	timer.last = duration
+	if duration > timer.max {
+		timer.max = duration
+	}
+
+	// Moving average, weighing recent ticks more
+	if timer.average == 0 {
+		timer.average = duration
+	} else {
+		timer.average += (duration - timer.average) / 10
+	}
+}
+
+// Info snapshot of a room for operators
+type Info struct {
+	ID          rose.RoomID
+	State       int
+	Owner       rose.UserID
+	Members     []rose.UserID
+	Spectators  []rose.UserID
+	Created     time.Time
+	Ticks       uint64
+	TickRate    int
+	LastTick    time.Duration
+	AverageTick time.Duration
+	MaxTick     time.Duration
+	MessagesIn  uint64
+	MessagesOut uint64
+	InRate      float64 // Messages per second
+	OutRate     float64
+}
+
+// info describe the room, has to run in the room loop
+func (room *Room) info() Info {
+	now := room.now()
+	room.messagesIn.roll(now)
+	room.messagesOut.roll(now)
+
+	info := Info{
+		ID:          room.ID,
+		State:       room.state,
+		Owner:       room.owner,
+		Members:     make([]rose.UserID, 0, len(room.members)),
+		Spectators:  make([]rose.UserID, 0, len(room.spectators)),
+		Created:     room.created,
+		Ticks:       room.tick,
+		TickRate:    int(time.Second / room.tickInterval),
+		LastTick:    room.tickTimer.last,
+		AverageTick: room.tickTimer.average,
+		MaxTick:     room.tickTimer.max,
+		MessagesIn:  room.messagesIn.total,
+		MessagesOut: room.messagesOut.total,
+		InRate:      room.messagesIn.rate,
+		OutRate:     room.messagesOut.rate,
+	}
+
+	for id := range room.members {
+		info.Members = append(info.Members, id)
+	}
+	for id := range room.spectators {
+		info.Spectators = append(info.Spectators, id)
+	}
+	sort.Slice(info.Members, func(i, j int) bool { return info.Members[i] < info.Members[j] })
+	sort.Slice(info.Spectators, func(i, j int) bool { return info.Spectators[i] < info.Spectators[j] })
+
+	return info
+}
+
+// Inspect describe the room with the given id, returns false if there is no such room
+// or it didn't answer in time
+func Inspect(id rose.RoomID) (Info, bool) {
+	result := make(chan Info, 1)
+	ok := Do(id, func(room *Room) {
+		result <- room.info()
+	})
+	if !ok {
+		return Info{}, false
+	}
+
+	select {
+	case info := <-result:
+		return info, true
+	case <-time.After(inspectTimeout):
+		log.Warningf("Room %d did not answer inspection in time", id)
+		return Info{}, false
+	}
+}
+
+// List describe every room on this node, rooms that don't answer in time are left out
+func List() []Info {
+	roomsLock.RLock()
+	ids := make([]rose.RoomID, 0, len(rooms))
+	for id := range rooms {
+		ids = append(ids, id)
+	}
+	roomsLock.RUnlock()
+
+	// Ask all rooms at once, they answer from their own loops
+	result := make(chan Info, len(ids))
+	asked := 0
+	for _, id := range ids {
+		if Do(id, func(room *Room) { result <- room.info() }) {
+			asked++
+		}
+	}
+
+	infos := make([]Info, 0, asked)
+	timeout := time.After(inspectTimeout)
+	for len(infos) < asked {
+		select {
+		case info := <-result:
+			infos = append(infos, info)
+		case <-timeout:
+			log.Warningf("%d rooms did not answer inspection in time", asked-len(infos))
+			asked = len(infos)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// DoAll run the action on every room on this node, returns the number of rooms
+func DoAll(action func(*Room)) int {
+	roomsLock.RLock()
+	ids := make([]rose.RoomID, 0, len(rooms))
+	for id := range rooms {
+		ids = append(ids, id)
+	}
+	roomsLock.RUnlock()
+
+	count := 0
+	for _, id := range ids {
+		if Do(id, action) {
+			count++
+		}
+	}
+	return count
+}
+
+// Announce send a system message to everyone in the room
+func (room *Room) Announce(message string) {
+	notice := &pb.SystemNotice{
+		Id:      uint64(room.ID),
+		Message: message,
+	}
+	room.recordEvent(pb.MessageType_SystemMessage, notice)
+	room.broadcast(pb.MessageType_SystemMessage, notice)
+	room.remember(pb.RoomChatEntry_System, 0, message)
+}
@@ -1,34 +1,61 @@
 package room
 
 import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/gameserver/node"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
 )
 
 func (room *Room) generateRoomInfo() *pb.RoomInfo {
 	info := &pb.RoomInfo{
-		Id: uint64(room.ID),
-		// TODO Fill
+		Id:             uint64(room.ID),
+		PlayerCount:    int32(len(room.members)),
+		SpectatorCount: int32(len(room.spectators)),
+		State:          int32(room.state),
+		Members:        make([]uint64, 0, len(room.members)),
+		InviteOnly:     room.inviteOnly,
+		// TODO Fill name and player max
+	}
+
+	for id := range room.members {
+		info.Members = append(info.Members, uint64(id))
 	}
 
 	return info
 }
 
-func (room *Room) updateMasterInfo(removed bool) {
+// updateMasterInfo tell the master about the room, as part of the trace in ctx if there is one
+func (room *Room) updateMasterInfo(ctx context.Context, removed bool) {
+	// Inform master about the updated room
+	roominfo := &pb.UpdateRoomRequest{
+		Room:        room.generateRoomInfo(),
+		Remove:      removed,
+		Reason:      room.closeReason,
+		TraceParent: tracing.Inject(ctx),
+	}
+
+	// Send room info to master
+	sendToMaster(pb.MessageType_UpdateRoom, roominfo)
+}
+
+// sendToMaster send a message to the master, returns false if we're not connected
+func sendToMaster(messageType pb.MessageType, message proto.Message) bool {
+	// Replays are played back without a node
+	if node.Instance == nil {
+		return false
+	}
+
 	node.Instance.RLock()
 	defer node.Instance.RUnlock()
 
 	if node.Instance.Master == nil {
-		return
-	}
-
-	// Inform master about the updated room
-	roominfo := &pb.UpdateRoomRequest{
-		Room:   room.generateRoomInfo(),
-		Remove: removed,
+		return false
 	}
 
-	// Send room info to master
-	node.Instance.Master.SendMessage(rose.MessageType(pb.MessageType_UpdateRoom), roominfo)
+	node.Instance.Master.SendMessage(rose.MessageType(messageType), message)
+	return true
 }
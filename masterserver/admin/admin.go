@@ -0,0 +1,54 @@
+// Package admin serves the HTTP API operators use to inspect and manage the cluster.
+// It listens separately from the rose websocket endpoints. Managing the cluster needs the admin token,
+// /metrics, /healthz and /readyz are open to anyone.
+package admin
+
+import (
+	"net/http"
+
+	"github.com/op/go-logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/shared/adminapi"
+)
+
+var log = logging.MustGetLogger("admin")
+
+// Serve start the admin API on the given address, the listener runs until the process exits.
+// Without a token only the metrics and health checks are served.
+// The master isn't ready until it has a node in each of the required regions.
+func Serve(address string, token string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nodes", handleNodes)
+	mux.HandleFunc("/nodes/", handleNode)
+	mux.HandleFunc("/rooms", handleRooms)
+	mux.HandleFunc("/rooms/", handleRoom)
+	mux.HandleFunc("/users", handleUsers)
+	mux.HandleFunc("/users/", handleUser)
+
+	// Metrics and health are for anyone to check
+	prometheus.MustRegister(newClusterCollector())
+	public := map[string]http.Handler{
+		"/metrics": promhttp.Handler(),
+		"/healthz": adminapi.HealthHandler(),
+		"/readyz":  adminapi.ReadyHandler(ready),
+	}
+
+	addr, err := adminapi.Serve(address, token, mux, public)
+	if err != nil {
+		return err
+	}
+
+	log.Noticef("Admin API serving at %s", addr)
+	return nil
+}
+
+// userIDs convert ids to plain numbers, so they show up as such in JSON
+func userIDs(ids []rose.UserID) []uint64 {
+	result := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, uint64(id))
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/node"
+)
+
+// clusterCollector reports the state of the cluster as it is when scraped
+type clusterCollector struct {
+	nodes *prometheus.Desc
+	rooms *prometheus.Desc
+}
+
+func newClusterCollector() *clusterCollector {
+	return &clusterCollector{
+		nodes: prometheus.NewDesc("master_nodes", "Number of registered nodes, by region and whether they are draining.", []string{"region", "draining"}, nil),
+		rooms: prometheus.NewDesc("master_rooms", "Number of rooms, by region and state.", []string{"region", "state"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (collector *clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.nodes
+	ch <- collector.rooms
+}
+
+// Collect implements prometheus.Collector
+func (collector *clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	type nodeKey struct {
+		region   string
+		draining bool
+	}
+	nodes := make(map[nodeKey]int)
+	for _, status := range node.Cluster.GetStatus() {
+		// Nodes that haven't told us where they are yet don't count
+		if !status.Registered {
+			continue
+		}
+		nodes[nodeKey{status.Region, status.Draining}]++
+	}
+	for key, count := range nodes {
+		ch <- prometheus.MustNewConstMetric(collector.nodes, prometheus.GaugeValue, float64(count), key.region, strconv.FormatBool(key.draining))
+	}
+
+	type roomKey struct {
+		region string
+		state  int
+	}
+	rooms := make(map[roomKey]int)
+	for _, room := range lobby.GetRooms() {
+		key := roomKey{state: room.State}
+		if server, ok := room.Server.(*node.User); ok {
+			key.region = server.Region
+		}
+		rooms[key]++
+	}
+	for key, count := range rooms {
+		ch <- prometheus.MustNewConstMetric(collector.rooms, prometheus.GaugeValue, float64(count), key.region, strconv.Itoa(key.state))
+	}
+}
@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/node"
+)
+
+// Serving states
+const (
+	notServing = iota
+	serving
+	stopping
+)
+
+// state whether the game endpoints are up
+var state int32
+
+// MarkServing tell the admin API the master is serving clients and nodes
+func MarkServing() {
+	atomic.StoreInt32(&state, serving)
+}
+
+// MarkStopping tell the admin API the master is shutting down
+func MarkStopping() {
+	atomic.StoreInt32(&state, stopping)
+}
+
+// ready returns why the master is not ready, or nil if it is
+func ready() error {
+	switch atomic.LoadInt32(&state) {
+	case notServing:
+		return errors.New("not serving yet")
+	case stopping:
+		return errors.New("shutting down")
+	}
+
+	requiredRegions := config.Get().RequiredRegions
+	if len(requiredRegions) == 0 {
+		return nil
+	}
+
+	// Clients can't play in regions without a node taking rooms
+	available := make(map[string]bool)
+	for _, status := range node.Cluster.GetStatus() {
+		if status.Registered && !status.Draining {
+			available[status.Region] = true
+		}
+	}
+	for _, region := range requiredRegions {
+		if !available[region] {
+			return fmt.Errorf("no node for region %s", region)
+		}
+	}
+
+	return nil
+}
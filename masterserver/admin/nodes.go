@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/node"
+	"github.com/zeroZshadow/rose-example/shared/adminapi"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+// nodeInfo describes a node to operators
+type nodeInfo struct {
+	ID         uint64    `json:"id"`
+	Address    string    `json:"address"`
+	Region     string    `json:"region"`
+	Rooms      int       `json:"rooms"`
+	RoomMax    int       `json:"roommax"`
+	Registered bool      `json:"registered"`
+	Draining   bool      `json:"draining"`
+	Connected  time.Time `json:"connected"`
+	LastSeen   time.Time `json:"lastseen"`
+}
+
+func toNodeInfo(status node.Status) nodeInfo {
+	return nodeInfo{
+		ID:         uint64(status.ID),
+		Address:    status.Address,
+		Region:     status.Region,
+		Rooms:      status.RoomCount,
+		RoomMax:    status.RoomMax,
+		Registered: status.Registered,
+		Draining:   status.Draining,
+		Connected:  status.Connected,
+		LastSeen:   status.LastSeen,
+	}
+}
+
+// handleNodes GET /nodes
+func handleNodes(w http.ResponseWriter, r *http.Request) {
+	if !adminapi.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	nodes := make([]nodeInfo, 0)
+	for _, status := range node.Cluster.GetStatus() {
+		nodes = append(nodes, toNodeInfo(status))
+	}
+
+	adminapi.WriteJSON(w, http.StatusOK, nodes)
+}
+
+// handleNode GET /nodes/{id}, POST /nodes/{id}/drain and POST /nodes/{id}/undrain
+func handleNode(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := adminapi.SplitPath(r.URL.Path, "/nodes/")
+	if !ok {
+		adminapi.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	server := node.Cluster.GetNode(rose.UserID(id))
+	if server == nil {
+		adminapi.WriteError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	switch action {
+	case "":
+		if !adminapi.RequireMethod(w, r, http.MethodGet) {
+			return
+		}
+		for _, status := range node.Cluster.GetStatus() {
+			if status.ID == server.ID {
+				adminapi.WriteJSON(w, http.StatusOK, toNodeInfo(status))
+				return
+			}
+		}
+		adminapi.WriteError(w, http.StatusNotFound, "node not found")
+
+	case "drain", "undrain":
+		if !adminapi.RequireMethod(w, r, http.MethodPost) {
+			return
+		}
+		server.Drain(action == "drain")
+		log.Noticef("Admin %s node %d from %s", action, id, r.RemoteAddr)
+		audit.Record("node."+action, audit.Admin(r.RemoteAddr), audit.Node(id), nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		adminapi.WriteError(w, http.StatusNotFound, "unknown action")
+	}
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/node"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/adminapi"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+// roomInfo describes a room to operators
+type roomInfo struct {
+	ID          uint64   `json:"id"`
+	Name        string   `json:"name"`
+	Node        uint64   `json:"node"`
+	Region      string   `json:"region"`
+	State       int      `json:"state"`
+	PlayerCount int      `json:"playercount"`
+	PlayerMax   int      `json:"playermax"`
+	Spectators  int      `json:"spectators"`
+	Members     []uint64 `json:"members,omitempty"`
+}
+
+func toRoomInfo(room lobby.RoomInfo, members bool) roomInfo {
+	info := roomInfo{
+		ID:          uint64(room.ID),
+		Name:        room.Name,
+		State:       room.State,
+		PlayerCount: room.PlayerCount,
+		PlayerMax:   room.PlayerMax,
+		Spectators:  room.SpectatorCount,
+	}
+
+	if server, ok := room.Server.(*node.User); ok {
+		info.Node = uint64(server.ID)
+		info.Region = server.Region
+	}
+	if members {
+		info.Members = userIDs(room.Members)
+	}
+
+	return info
+}
+
+// handleRooms GET /rooms, optionally filtered with ?region= and ?node=
+func handleRooms(w http.ResponseWriter, r *http.Request) {
+	if !adminapi.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	region := r.URL.Query().Get("region")
+	nodeID, err := strconv.ParseUint(r.URL.Query().Get("node"), 10, 64)
+	filterNode := err == nil
+
+	rooms := make([]roomInfo, 0)
+	for _, room := range lobby.GetRooms() {
+		info := toRoomInfo(room, false)
+		if region != "" && info.Region != region {
+			continue
+		}
+		if filterNode && info.Node != nodeID {
+			continue
+		}
+		rooms = append(rooms, info)
+	}
+
+	adminapi.WriteJSON(w, http.StatusOK, rooms)
+}
+
+// handleRoom GET /rooms/{id} and POST /rooms/{id}/close
+func handleRoom(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := adminapi.SplitPath(r.URL.Path, "/rooms/")
+	if !ok {
+		adminapi.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	room, ok := lobby.GetRoomInfo(rose.RoomID(id))
+	if !ok {
+		adminapi.WriteError(w, http.StatusNotFound, "room not found")
+		return
+	}
+
+	switch action {
+	case "":
+		if !adminapi.RequireMethod(w, r, http.MethodGet) {
+			return
+		}
+		adminapi.WriteJSON(w, http.StatusOK, toRoomInfo(room, true))
+
+	case "close":
+		if !adminapi.RequireMethod(w, r, http.MethodPost) {
+			return
+		}
+		server, ok := room.Server.(*node.User)
+		if !ok {
+			adminapi.WriteError(w, http.StatusInternalServerError, "room has no node")
+			return
+		}
+
+		// The node tells us once the room is gone
+		server.CloseRoom(room.ID, pb.RoomCloseReason_Admin)
+		log.Noticef("Admin closed room %d from %s", id, r.RemoteAddr)
+		audit.Record("room.close", audit.Admin(r.RemoteAddr), audit.Room(id), nil)
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		adminapi.WriteError(w, http.StatusNotFound, "unknown action")
+	}
+}
@@ -0,0 +1,159 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/node"
+	"github.com/zeroZshadow/rose-example/shared/adminapi"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+// userInfo describes a connected user to operators
+type userInfo struct {
+	ID   uint64 `json:"id"`
+	Room uint64 `json:"room,omitempty"`
+}
+
+func toUserInfo(id rose.UserID) userInfo {
+	info := userInfo{
+		ID: uint64(id),
+	}
+	if roomID, ok := lobby.GetUserRoom(id); ok {
+		info.Room = uint64(roomID)
+	}
+	return info
+}
+
+// handleUsers GET /users
+func handleUsers(w http.ResponseWriter, r *http.Request) {
+	if !adminapi.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	users := make([]userInfo, 0)
+	for _, user := range lobby.GetUsers() {
+		users = append(users, toUserInfo(user.Base().ID))
+	}
+
+	adminapi.WriteJSON(w, http.StatusOK, users)
+}
+
+// handleUser GET /users/{id}, POST /users/{id}/kick, POST /users/{id}/ban and POST /users/{id}/unban.
+// Kicks and bans take an optional ?reason=, bans an optional ?duration= like 24h, without one they're permanent.
+func handleUser(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := adminapi.SplitPath(r.URL.Path, "/users/")
+	if !ok {
+		adminapi.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	// Bans also apply to users that aren't here
+	userID := rose.UserID(id)
+	switch action {
+	case "ban":
+		handleBan(w, r, userID)
+		return
+	case "unban":
+		handleUnban(w, r, userID)
+		return
+	}
+
+	_, connected := lobby.GetUser(userID)
+	_, playing := lobby.GetUserRoom(userID)
+	if !connected && !playing {
+		adminapi.WriteError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	switch action {
+	case "":
+		if !adminapi.RequireMethod(w, r, http.MethodGet) {
+			return
+		}
+		adminapi.WriteJSON(w, http.StatusOK, toUserInfo(userID))
+
+	case "kick":
+		if !adminapi.RequireMethod(w, r, http.MethodPost) {
+			return
+		}
+		reason := r.URL.Query().Get("reason")
+		kick(userID, reason)
+
+		log.Noticef("Admin kicked user %d from %s: %s", id, r.RemoteAddr, reason)
+		audit.Record("user.kick", audit.Admin(r.RemoteAddr), audit.User(id), map[string]string{"reason": reason})
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		adminapi.WriteError(w, http.StatusNotFound, "unknown action")
+	}
+}
+
+// handleBan keep the user from playing, and kick them if they're here
+func handleBan(w http.ResponseWriter, r *http.Request, userID rose.UserID) {
+	if !adminapi.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	ban := &storage.Ban{
+		UserID:  userID,
+		Reason:  r.URL.Query().Get("reason"),
+		Created: time.Now(),
+	}
+	if raw := r.URL.Query().Get("duration"); raw != "" {
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration <= 0 {
+			adminapi.WriteError(w, http.StatusBadRequest, "invalid duration")
+			return
+		}
+		ban.Expires = ban.Created.Add(duration)
+	}
+
+	if err := storage.Instance.SaveBan(ban); err != nil {
+		log.Errorf("Failed to ban user %d: %s", userID, err)
+		adminapi.WriteError(w, http.StatusInternalServerError, "failed to save the ban")
+		return
+	}
+	kick(userID, ban.Reason)
+
+	log.Noticef("Admin banned user %d from %s: %s", userID, r.RemoteAddr, ban.Reason)
+	audit.Record("user.ban", audit.Admin(r.RemoteAddr), audit.User(uint64(userID)), map[string]string{
+		"reason":   ban.Reason,
+		"duration": r.URL.Query().Get("duration"),
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleUnban let the user play again
+func handleUnban(w http.ResponseWriter, r *http.Request, userID rose.UserID) {
+	if !adminapi.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := storage.Instance.RemoveBan(userID); err != nil {
+		log.Errorf("Failed to unban user %d: %s", userID, err)
+		adminapi.WriteError(w, http.StatusInternalServerError, "failed to remove the ban")
+		return
+	}
+
+	log.Noticef("Admin unbanned user %d from %s", userID, r.RemoteAddr)
+	audit.Record("user.unban", audit.Admin(r.RemoteAddr), audit.User(uint64(userID)), nil)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// kick get the user out of their room first, then off the master
+func kick(userID rose.UserID, reason string) {
+	if roomID, playing := lobby.GetUserRoom(userID); playing {
+		if room, ok := lobby.GetRoomInfo(roomID); ok {
+			if server, ok := room.Server.(*node.User); ok {
+				server.KickUser(roomID, userID, reason)
+			}
+		}
+	}
+	if user, connected := lobby.GetUser(userID); connected {
+		user.Disconnect()
+	}
+}
@@ -0,0 +1,293 @@
+package chat
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+var log = logging.MustGetLogger("chat")
+
+const maxMessageLength = 512
+
+var (
+	// ErrInvalidChannel channel names are limited to letters, digits and - _ : .
+	ErrInvalidChannel = errors.New("chat: invalid channel name")
+	// ErrNotMember the user has not joined the channel
+	ErrNotMember = errors.New("chat: not in channel")
+	// ErrUnavailable the whispered user can't be reached. Unknown, offline and blocking users look the same,
+	// so whispers can't be used to find out who exists or who blocked the sender.
+	ErrUnavailable = errors.New("chat: user is not available")
+	// ErrInvalidMessage the message is empty or too long
+	ErrInvalidMessage = errors.New("chat: invalid message")
+	// ErrNotAllowed the user may not join the channel
+	ErrNotAllowed = errors.New("chat: not allowed in channel")
+	// ErrTooManyChannels the user joined as many channels as allowed
+	ErrTooManyChannels = errors.New("chat: too many channels")
+)
+
+// partyPrefix party channels are named after the user that leads the party, as in "party:1234"
+const partyPrefix = "party:"
+
+// HistorySize the number of messages each channel keeps for users that join later
+var HistorySize = 50
+
+// MaxChannels the number of channels a user can be in at once
+var MaxChannels = 10
+
+var channelName = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,32}$`)
+
+// channel a named group of users talking to each other
+type channel struct {
+	name    string
+	members map[rose.UserID]rose.User
+
+	// Ring buffer of the most recent messages, next is where the next message goes
+	history []*pb.ChannelChatMessage
+	next    int
+	full    bool
+}
+
+var (
+	channels = make(map[string]*channel)
+	// Channels joined per user, to clean up when they disconnect
+	joined = make(map[rose.UserID]map[string]bool)
+	// Guards channels and joined
+	lock sync.Mutex
+)
+
+// recent the history of the channel, oldest first
+func (c *channel) recent() []*pb.ChannelChatMessage {
+	if !c.full {
+		return append([]*pb.ChannelChatMessage(nil), c.history[:c.next]...)
+	}
+	return append(append([]*pb.ChannelChatMessage(nil), c.history[c.next:]...), c.history[:c.next]...)
+}
+
+// remember add a message to the history, overwriting the oldest once full
+func (c *channel) remember(message *pb.ChannelChatMessage) {
+	if len(c.history) == 0 {
+		return
+	}
+
+	c.history[c.next] = message
+	c.next = (c.next + 1) % len(c.history)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+func (c *channel) memberIDs() []uint64 {
+	ids := make([]uint64, 0, len(c.members))
+	for id := range c.members {
+		ids = append(ids, uint64(id))
+	}
+	return ids
+}
+
+// broadcast send a message to all members of the channel except one
+func (c *channel) broadcast(messageType pb.MessageType, message proto.Message, except rose.UserID) {
+	for id, member := range c.members {
+		if id != except {
+			member.SendMessage(rose.MessageType(messageType), message)
+		}
+	}
+}
+
+// allowed returns an error if the user may not join the channel.
+// Anyone may join a channel, except for party channels which are for the leader and their friends.
+func allowed(userID rose.UserID, name string) error {
+	if !strings.HasPrefix(name, partyPrefix) {
+		return nil
+	}
+
+	leader, err := strconv.ParseUint(strings.TrimPrefix(name, partyPrefix), 10, 64)
+	if err != nil {
+		return ErrInvalidChannel
+	}
+	if rose.UserID(leader) == userID {
+		return nil
+	}
+
+	friends, err := social.Friends(rose.UserID(leader), userID)
+	if err != nil {
+		return err
+	}
+	if !friends {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// Join add the user to a channel, creating it if needed. Returns the members and recent history.
+func Join(user rose.User, name string) ([]uint64, []*pb.ChannelChatMessage, error) {
+	if !channelName.MatchString(name) {
+		return nil, nil, ErrInvalidChannel
+	}
+
+	userID := user.Base().ID
+	if err := allowed(userID, name); err != nil {
+		return nil, nil, err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !joined[userID][name] && len(joined[userID]) >= MaxChannels {
+		return nil, nil, ErrTooManyChannels
+	}
+
+	c, ok := channels[name]
+	if !ok {
+		c = &channel{
+			name:    name,
+			members: make(map[rose.UserID]rose.User),
+			history: make([]*pb.ChannelChatMessage, HistorySize),
+		}
+		channels[name] = c
+	}
+
+	if _, ok := c.members[userID]; !ok {
+		c.broadcast(pb.MessageType_ChannelMemberJoined, &pb.ChannelMemberUpdate{Channel: name, UserId: uint64(userID)}, userID)
+	}
+	c.members[userID] = user
+
+	if joined[userID] == nil {
+		joined[userID] = make(map[string]bool)
+	}
+	joined[userID][name] = true
+
+	return c.memberIDs(), c.recent(), nil
+}
+
+// Leave remove the user from a channel
+func Leave(userID rose.UserID, name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return leave(userID, name)
+}
+
+// leave remove the user from a channel, must be called with the lock held
+func leave(userID rose.UserID, name string) error {
+	c, ok := channels[name]
+	if !ok {
+		return ErrNotMember
+	}
+	if _, ok := c.members[userID]; !ok {
+		return ErrNotMember
+	}
+
+	delete(c.members, userID)
+	delete(joined[userID], name)
+	if len(joined[userID]) == 0 {
+		delete(joined, userID)
+	}
+
+	// Channels only live as long as someone is in them
+	if len(c.members) == 0 {
+		delete(channels, name)
+		return nil
+	}
+
+	c.broadcast(pb.MessageType_ChannelMemberLeft, &pb.ChannelMemberUpdate{Channel: name, UserId: uint64(userID)}, userID)
+	return nil
+}
+
+// LeaveAll remove the user from every channel they joined
+func LeaveAll(userID rose.UserID) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for name := range joined[userID] {
+		leave(userID, name)
+	}
+}
+
+// Members the ids of everyone in a channel the user has joined
+func Members(userID rose.UserID, name string) ([]uint64, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, ok := channels[name]
+	if !ok {
+		return nil, ErrNotMember
+	}
+	if _, ok := c.members[userID]; !ok {
+		return nil, ErrNotMember
+	}
+	return c.memberIDs(), nil
+}
+
+// Say send a message to everyone in a channel the sender has joined
+func Say(from rose.UserID, name string, text string) error {
+	if text == "" || len(text) > maxMessageLength {
+		return ErrInvalidMessage
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, ok := channels[name]
+	if !ok {
+		return ErrNotMember
+	}
+	if _, ok := c.members[from]; !ok {
+		return ErrNotMember
+	}
+
+	message := &pb.ChannelChatMessage{
+		Channel:    name,
+		FromUserId: uint64(from),
+		Message:    text,
+		Timestamp:  time.Now().UnixNano() / 1e6,
+	}
+
+	c.remember(message)
+	c.broadcast(pb.MessageType_ChannelMessage, message, 0)
+	return nil
+}
+
+// Whisper send a private message to another online user, the sender gets a copy
+func Whisper(from rose.User, to rose.UserID, text string) error {
+	if text == "" || len(text) > maxMessageLength {
+		return ErrInvalidMessage
+	}
+
+	target, ok := lobby.GetUser(to)
+	if !ok {
+		return ErrUnavailable
+	}
+
+	// Blocked users don't get to bother anyone
+	blocked, err := social.Blocked(to, from.Base().ID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrUnavailable
+	}
+
+	message := &pb.WhisperMessage{
+		FromUserId: uint64(from.Base().ID),
+		ToUserId:   uint64(to),
+		Message:    text,
+		Timestamp:  time.Now().UnixNano() / 1e6,
+	}
+
+	target.SendMessage(rose.MessageType(pb.MessageType_Whisper), message)
+	from.SendMessage(rose.MessageType(pb.MessageType_Whisper), message)
+
+	log.Debugf("Whisper from %d to %d", from.Base().ID, to)
+	return nil
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/chat"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+func handleWhisperRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.WhisperRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	if !user.allow("chat", cfg.ChatRate, cfg.ChatBurst) {
+		sendChatError(user, messageType, "", errRateLimited)
+		return nil
+	}
+
+	// Delivery is confirmed by receiving a copy of the whisper
+	err = chat.Whisper(user, rose.UserID(input.UserId), input.Message)
+	if err != nil {
+		sendChatError(user, messageType, "", err)
+	}
+
+	return nil
+}
+
+func handleJoinChannelRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.ChannelRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	members, history, err := chat.Join(user, input.Channel)
+	if err != nil {
+		sendChatError(user, messageType, input.Channel, err)
+		return nil
+	}
+
+	// Create response
+	response := &pb.ChannelJoinResponse{
+		Channel: input.Channel,
+		Members: members,
+		History: history,
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+
+	return nil
+}
+
+func handleLeaveChannelRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.ChannelRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	err = chat.Leave(user.ID, input.Channel)
+	if err != nil {
+		sendChatError(user, messageType, input.Channel, err)
+		return nil
+	}
+
+	// Confirm by echoing the request
+	user.SendMessage(rose.MessageType(messageType), input)
+
+	return nil
+}
+
+func handleChannelMembersRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.ChannelRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	members, err := chat.Members(user.ID, input.Channel)
+	if err != nil {
+		sendChatError(user, messageType, input.Channel, err)
+		return nil
+	}
+
+	// Create response
+	response := &pb.ChannelMembersResponse{
+		Channel: input.Channel,
+		Members: members,
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+
+	return nil
+}
+
+func handleChannelMessage(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.ChannelChatMessage{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	if !user.allow("chat", cfg.ChatRate, cfg.ChatBurst) {
+		sendChatError(user, messageType, input.Channel, errRateLimited)
+		return nil
+	}
+
+	// The sender gets the message back like everyone else
+	err = chat.Say(user.ID, input.Channel, input.Message)
+	if err != nil {
+		sendChatError(user, messageType, input.Channel, err)
+	}
+
+	return nil
+}
+
+func sendChatError(user *User, messageType pb.MessageType, channel string, err error) {
+	// Create response
+	response := &pb.ChatErrorResponse{
+		Request: messageType,
+		Channel: channel,
+		Reason:  err.Error(),
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(pb.MessageType_ChatError), response)
+}
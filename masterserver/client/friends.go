@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+// errRateLimited the user is doing this too often
+var errRateLimited = errors.New("client: rate limited")
+
+func handleFriendActionRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.FriendActionRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendFriendActionResponse(user, messageType, false, 0)
+		return err
+	}
+
+	other := rose.UserID(input.UserId)
+	switch messageType {
+	case pb.MessageType_AddFriend:
+		cfg := config.Get()
+		if !user.allow("friend", cfg.FriendRate, cfg.FriendBurst) {
+			err = errRateLimited
+			break
+		}
+		err = social.SendRequest(user.ID, other)
+	case pb.MessageType_AcceptFriend:
+		err = social.Accept(user.ID, other)
+	case pb.MessageType_DeclineFriend:
+		err = social.Decline(user.ID, other)
+	case pb.MessageType_RemoveFriend:
+		err = social.Remove(user.ID, other)
+	case pb.MessageType_BlockUser:
+		err = social.Block(user.ID, other)
+	}
+
+	if err != nil {
+		log.Debugf("Friend action %s by user %d on %d failed: %s", messageType, user.ID, other, err)
+	}
+
+	sendFriendActionResponse(user, messageType, err == nil, other)
+
+	return nil
+}
+
+func sendFriendActionResponse(user *User, messageType pb.MessageType, success bool, other rose.UserID) {
+	// Create response
+	response := &pb.FriendActionResponse{
+		Success: success,
+		UserId:  uint64(other),
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
+
+func handleListFriendsRequest(user *User, messageType pb.MessageType, message []byte) error {
+	response, err := social.FriendList(user.ID)
+	if err != nil {
+		log.Errorf("Failed to list friends of user %d: %s", user.ID, err)
+		response = &pb.FriendListResponse{Success: false}
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+
+	return nil
+}
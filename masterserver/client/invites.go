@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+func handleInviteRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.InviteRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendInviteResponse(user, messageType, false, 0)
+		return err
+	}
+
+	cfg := config.Get()
+	if !user.allow("invite", cfg.InviteRate, cfg.InviteBurst) {
+		log.Debugf("User %d is sending invites too fast", user.ID)
+		sendInviteResponse(user, messageType, false, rose.UserID(input.UserId))
+		return nil
+	}
+
+	timeout := time.Duration(cfg.InviteTimeout) * time.Second
+	err = social.Invite(user.ID, rose.UserID(input.UserId), rose.RoomID(input.RoomId), timeout)
+	if err != nil {
+		log.Debugf("User %d failed to invite %d to room %d: %s", user.ID, input.UserId, input.RoomId, err)
+	}
+
+	sendInviteResponse(user, messageType, err == nil, rose.UserID(input.UserId))
+
+	return nil
+}
+
+func sendInviteResponse(user *User, messageType pb.MessageType, success bool, invited rose.UserID) {
+	// Create response
+	response := &pb.InviteResponse{
+		Success: success,
+		UserId:  uint64(invited),
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
+
+func handleAcceptInviteRequest(user *User, messageType pb.MessageType, message []byte) error {
+	responseType := pb.MessageType_AcceptInvite
+
+	input := &pb.AcceptInviteRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		countRoomRequest(responseType, "bad_request")
+		sendRoomResponse(user, responseType, false, 0, "", nil)
+		return err
+	}
+
+	// The invite is all the permission needed to join
+	roomID := rose.RoomID(input.RoomId)
+	if err := social.CheckInvite(user.ID, roomID); err != nil {
+		countRoomRequest(responseType, "no_invite")
+		sendRoomResponse(user, responseType, false, roomID, "", nil)
+		return nil
+	}
+
+	// Keep the invite around if joining failed, so they can try again
+	if sendJoinToken(context.Background(), user, responseType, roomID, false) {
+		social.ConsumeInvite(user.ID, roomID)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/leaderboard"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+const (
+	maxLeaderboardPage   = 100
+	maxLeaderboardWindow = 25
+)
+
+func handleGetLeaderboardRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.GetLeaderboardRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendLeaderboardResponse(user, messageType, input.Board, false, "", 0, nil)
+		return err
+	}
+
+	board, ok := leaderboard.Get(input.Board)
+	if !ok {
+		sendLeaderboardResponse(user, messageType, input.Board, false, "", 0, nil)
+		return nil
+	}
+
+	// Keep pages reasonable
+	limit := int(input.Limit)
+	if limit <= 0 || limit > maxLeaderboardPage {
+		limit = maxLeaderboardPage
+	}
+
+	season, total, entries := board.Top(int(input.Offset), limit)
+	sendLeaderboardResponse(user, messageType, input.Board, true, season, total, entries)
+
+	return nil
+}
+
+func handleGetMyRankRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.GetMyRankRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendLeaderboardResponse(user, messageType, input.Board, false, "", 0, nil)
+		return err
+	}
+
+	board, ok := leaderboard.Get(input.Board)
+	if !ok {
+		sendLeaderboardResponse(user, messageType, input.Board, false, "", 0, nil)
+		return nil
+	}
+
+	window := int(input.Window)
+	if window < 0 || window > maxLeaderboardWindow {
+		window = maxLeaderboardWindow
+	}
+
+	season, total, entries := board.Around(user.ID, window)
+	sendLeaderboardResponse(user, messageType, input.Board, true, season, total, entries)
+
+	return nil
+}
+
+func sendLeaderboardResponse(user *User, messageType pb.MessageType, board string, success bool, season string, total int, entries []leaderboard.Entry) {
+	// Create response
+	response := &pb.LeaderboardResponse{
+		Success: success,
+		Board:   board,
+		Season:  season,
+		Total:   int32(total),
+		Entries: make([]*pb.LeaderboardEntry, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, &pb.LeaderboardEntry{
+			UserId: uint64(entry.UserID),
+			Rank:   int32(entry.Rank),
+			Score:  entry.Score,
+		})
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"time"
+)
+
+// bucket token bucket limiting how often a user can do something
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow take a token if one is available, refilling at rate tokens per second up to burst
+func (bucket *bucket) allow(now time.Time, rate float64, burst int) bool {
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+	if bucket.tokens > float64(burst) {
+		bucket.tokens = float64(burst)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// allow returns true if the user may do another action of the given kind, rate is in actions per second.
+// A rate of 0 doesn't limit the user.
+func (user *User) allow(kind string, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+
+	user.bucketsLock.Lock()
+	defer user.bucketsLock.Unlock()
+
+	now := time.Now()
+	limit, ok := user.buckets[kind]
+	if !ok {
+		limit = &bucket{tokens: float64(burst), last: now}
+		user.buckets[kind] = limit
+	}
+	return limit.allow(now, rate, burst)
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/results"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+const maxMatchHistoryPage = 50
+
+func handleGetMatchHistoryRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.GetMatchHistoryRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendMatchHistoryResponse(user, messageType, false, 0, nil)
+		return err
+	}
+
+	// No user id means our own history
+	userID := rose.UserID(input.UserId)
+	if userID == 0 {
+		userID = user.ID
+	}
+
+	// Keep pages reasonable
+	limit := int(input.Limit)
+	if limit <= 0 || limit > maxMatchHistoryPage {
+		limit = maxMatchHistoryPage
+	}
+	offset := int(input.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	matches, err := storage.Instance.GetMatchHistory(userID, offset, limit)
+	if err != nil {
+		log.Errorf("Failed to load match history of user %d: %s", userID, err)
+		sendMatchHistoryResponse(user, messageType, false, userID, nil)
+		return nil
+	}
+
+	sendMatchHistoryResponse(user, messageType, true, userID, matches)
+
+	return nil
+}
+
+func sendMatchHistoryResponse(user *User, messageType pb.MessageType, success bool, userID rose.UserID, matches []*storage.Match) {
+	// Create response
+	response := &pb.MatchHistoryResponse{
+		Success: success,
+		UserId:  uint64(userID),
+		Matches: make([]*pb.MatchInfo, 0, len(matches)),
+	}
+
+	for _, match := range matches {
+		response.Matches = append(response.Matches, results.MatchToMessage(match))
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
+
+func handleGetMatchRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.GetMatchRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendMatchResponse(user, messageType, nil)
+		return err
+	}
+
+	match, err := storage.Instance.GetMatch(rose.RoomID(input.Id))
+	if err != nil {
+		if err != storage.ErrNotFound {
+			log.Errorf("Failed to load match %d: %s", input.Id, err)
+		}
+		sendMatchResponse(user, messageType, nil)
+		return nil
+	}
+
+	sendMatchResponse(user, messageType, match)
+
+	return nil
+}
+
+func sendMatchResponse(user *User, messageType pb.MessageType, match *storage.Match) {
+	// Create response
+	response := &pb.MatchResponse{
+		Success: match != nil,
+	}
+	if match != nil {
+		response.Match = results.MatchToMessage(match)
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
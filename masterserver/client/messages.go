@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -8,12 +9,21 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/metrics"
 	"github.com/zeroZshadow/rose-example/masterserver/node"
+	"github.com/zeroZshadow/rose-example/masterserver/profiles"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
 	"github.com/zeroZshadow/rose-example/messages/pb"
 	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-var snowflakeNode *snowflake.Node
+var (
+	snowflakeNode *snowflake.Node
+	tracer        = tracing.Tracer("masterserver/client")
+)
 
 func init() {
 	// I am a terrible person that ignores the error (because I know it will never happen)
@@ -25,6 +35,25 @@ func SetupMessageHandlers() {
 	messageMap[pb.MessageType_CreateRoom] = handleCreateRoomRequest
 	messageMap[pb.MessageType_JoinRoom] = handleJoinRoomRequest
 	messageMap[pb.MessageType_ListRooms] = handleListRoomsRequest
+	messageMap[pb.MessageType_GetProfile] = handleGetProfileRequest
+	messageMap[pb.MessageType_UpdateProfile] = handleUpdateProfileRequest
+	messageMap[pb.MessageType_GetLeaderboard] = handleGetLeaderboardRequest
+	messageMap[pb.MessageType_GetMyRank] = handleGetMyRankRequest
+	messageMap[pb.MessageType_GetMatchHistory] = handleGetMatchHistoryRequest
+	messageMap[pb.MessageType_GetMatch] = handleGetMatchRequest
+	messageMap[pb.MessageType_AddFriend] = handleFriendActionRequest
+	messageMap[pb.MessageType_AcceptFriend] = handleFriendActionRequest
+	messageMap[pb.MessageType_DeclineFriend] = handleFriendActionRequest
+	messageMap[pb.MessageType_RemoveFriend] = handleFriendActionRequest
+	messageMap[pb.MessageType_BlockUser] = handleFriendActionRequest
+	messageMap[pb.MessageType_ListFriends] = handleListFriendsRequest
+	messageMap[pb.MessageType_Invite] = handleInviteRequest
+	messageMap[pb.MessageType_AcceptInvite] = handleAcceptInviteRequest
+	messageMap[pb.MessageType_Whisper] = handleWhisperRequest
+	messageMap[pb.MessageType_JoinChannel] = handleJoinChannelRequest
+	messageMap[pb.MessageType_LeaveChannel] = handleLeaveChannelRequest
+	messageMap[pb.MessageType_ChannelMembers] = handleChannelMembersRequest
+	messageMap[pb.MessageType_ChannelMessage] = handleChannelMessage
 }
 
 func handleCreateRoomRequest(user *User, messageType pb.MessageType, message []byte) error {
@@ -33,6 +62,7 @@ func handleCreateRoomRequest(user *User, messageType pb.MessageType, message []b
 	input := &pb.CreateRoomRequest{}
 	err := proto.Unmarshal(message, input)
 	if err != nil {
+		countRoomRequest(responseType, "bad_request")
 		sendRoomResponse(user, responseType, false, 0, "", nil)
 		return err
 	}
@@ -41,40 +71,43 @@ func handleCreateRoomRequest(user *User, messageType pb.MessageType, message []b
 	roomID := rose.RoomID(snowflakeNode.Generate())
 	log.Info("Requesting room with id", roomID)
 
+	// The trace follows the room to the node, until the user is in it
+	ctx, span := tracer.Start(context.Background(), "CreateRoom")
+	span.SetAttributes(
+		attribute.Int64("user.id", int64(user.ID)),
+		attribute.Int64("room.id", int64(roomID)),
+		attribute.String("region", input.Region),
+		attribute.String("room.type", input.Type),
+	)
+	defer span.End()
+
 	// Find best node to put the room on
+	_, pick := tracer.Start(ctx, "GetBestForRegion")
 	bestNode := node.Cluster.GetBestForRegion(input.Region)
+	pick.End()
 
 	// Fail if we didn't find a node
 	if bestNode == nil {
 		log.Errorf("No nodes found for region %s", input.Region)
+		span.SetStatus(codes.Error, "no node")
+		countRoomRequest(responseType, "no_node")
 		sendRoomResponse(user, responseType, false, roomID, "", nil)
 		return nil
 	}
+	span.SetAttributes(attribute.Int64("node.id", int64(bestNode.ID)))
 
 	// Generate data for response
-	roomrequest := shared.RoomRequest{
-		UserID:    user.ID,
-		RoomID:    roomID,
-		Timestamp: time.Now().UTC().UnixNano(),
-	}
-
-	// Marshall to json so it is ready to be encrypted
-	data, err := json.Marshal(roomrequest)
-	if err != nil {
-		log.Error("Failed to marshal room request:", err)
-		sendRoomResponse(user, responseType, false, roomID, "", nil)
-		return nil
-	}
-
-	// Encrypt
-	authtoken, err := bestNode.Encrypt(data)
+	authtoken, err := createRoomToken(ctx, user, roomID, bestNode, false, input.Type, int(input.Tickrate), input.InviteOnly)
 	if err != nil {
-		log.Error("Failed to encrypt room request:", err)
+		log.Error("Failed to create room token:", err)
+		tracing.Fail(span, err)
+		countRoomRequest(responseType, "token_error")
 		sendRoomResponse(user, responseType, false, roomID, "", nil)
 		return nil
 	}
 
 	// Send the new room info to the player
+	countRoomRequest(responseType, "ok")
 	sendRoomResponse(user, responseType, true, roomID, bestNode.Address, authtoken)
 
 	return nil
@@ -86,18 +119,59 @@ func handleJoinRoomRequest(user *User, messageType pb.MessageType, message []byt
 	input := &pb.JoinRoomRequest{}
 	err := proto.Unmarshal(message, input)
 	if err != nil {
+		countRoomRequest(responseType, "bad_request")
 		sendRoomResponse(user, responseType, false, 0, "", nil)
 		return err
 	}
 
+	// Invite-only rooms take an invite, which is used up once the user got in
 	roomID := rose.RoomID(input.Id)
+	inviteOnly := false
+	if info, ok := lobby.GetRoomInfo(roomID); ok && info.InviteOnly && !isMember(info, user.ID) {
+		if err := social.CheckInvite(user.ID, roomID); err != nil {
+			countRoomRequest(responseType, "no_invite")
+			sendRoomResponse(user, responseType, false, roomID, "", nil)
+			return nil
+		}
+		inviteOnly = true
+	}
+
+	if sendJoinToken(context.Background(), user, responseType, roomID, input.Spectate) && inviteOnly {
+		social.ConsumeInvite(user.ID, roomID)
+	}
+
+	return nil
+}
+
+// isMember returns true if the user is playing in the room
+func isMember(info lobby.RoomInfo, userID rose.UserID) bool {
+	for _, id := range info.Members {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// sendJoinToken send the user what they need to join an existing room, as a player or a spectator.
+// Returns true if the user got a token.
+func sendJoinToken(ctx context.Context, user *User, responseType pb.MessageType, roomID rose.RoomID, spectator bool) bool {
+	ctx, span := tracer.Start(ctx, "JoinRoom")
+	span.SetAttributes(
+		attribute.Int64("user.id", int64(user.ID)),
+		attribute.Int64("room.id", int64(roomID)),
+		attribute.Bool("spectator", spectator),
+	)
+	defer span.End()
 
 	// Pick a node based on the request parameters
 	info, ok := lobby.GetRoomInfo(roomID)
 	if !ok {
 		// Room wasn't found
+		span.SetStatus(codes.Error, "room not found")
+		countRoomRequest(responseType, "not_found")
 		sendRoomResponse(user, responseType, false, roomID, "", nil)
-		return nil
+		return false
 	}
 
 	// Generate data for response
@@ -105,37 +179,70 @@ func handleJoinRoomRequest(user *User, messageType pb.MessageType, message []byt
 	if !ok {
 		// Server was empty wasn't found
 		log.Error("Server for requestion room is nil.")
+		span.SetStatus(codes.Error, "no node")
+		countRoomRequest(responseType, "no_node")
 		sendRoomResponse(user, responseType, false, roomID, "", nil)
-		return nil
+		return false
 	}
 
 	address := server.Address
+	span.SetAttributes(attribute.Int64("node.id", int64(server.ID)))
 
-	roomrequest := shared.RoomRequest{
-		UserID:    user.ID,
-		RoomID:    roomID,
-		Timestamp: time.Now().UTC().UnixNano(),
+	authtoken, err := createRoomToken(ctx, user, roomID, server, spectator, "", 0, false)
+	if err != nil {
+		log.Error("Failed to create room token:", err)
+		tracing.Fail(span, err)
+		countRoomRequest(responseType, "token_error")
+		sendRoomResponse(user, responseType, false, roomID, "", nil)
+		return false
 	}
 
-	// Encrypt the room request using the node's public key
-	data, err := json.Marshal(roomrequest)
+	countRoomRequest(responseType, "ok")
+	sendRoomResponse(user, responseType, true, roomID, address, authtoken)
+	return true
+}
+
+// createRoomToken create the encrypted room request the user presents to the node.
+// The room type and tick rate only matter when creating a room.
+func createRoomToken(ctx context.Context, user *User, roomID rose.RoomID, server *node.User, spectator bool, roomType string, tickRate int, inviteOnly bool) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "createRoomToken")
+	defer span.End()
+
+	// Pass the player's profile along to the room, a broken profile shouldn't keep them from playing
+	profile, err := profiles.Get(user.ID)
 	if err != nil {
-		log.Error("Failed to marshal room request.")
-		sendRoomResponse(user, responseType, false, roomID, "", nil)
-		return nil
+		user.logger().Errorf("Failed to load profile, joining with the default: %s", err)
+		profile = profiles.Default(user.ID)
+	}
+
+	roomrequest := shared.RoomRequest{
+		UserID:     user.ID,
+		RoomID:     roomID,
+		Timestamp:  time.Now().UTC().UnixNano(),
+		Profile:    profiles.ToShared(profile),
+		Spectator:  spectator,
+		RoomType:   roomType,
+		TickRate:   tickRate,
+		InviteOnly: inviteOnly,
+		// The node continues the trace from the request
+		TraceParent: tracing.Inject(ctx),
 	}
 
-	// Encrypt
-	authtoken, err := server.Encrypt(data)
+	// Marshall to json so it is ready to be encrypted
+	data, err := json.Marshal(roomrequest)
 	if err != nil {
-		log.Error("Failed to encrypt room request.")
-		sendRoomResponse(user, responseType, false, roomID, "", nil)
-		return nil
+		return nil, err
 	}
 
-	sendRoomResponse(user, responseType, true, roomID, address, authtoken)
+	// Encrypt using the node's key
+	_, encrypt := tracer.Start(ctx, "Encrypt")
+	defer encrypt.End()
+	return server.Encrypt(data)
+}
 
-	return nil
+// countRoomRequest keep track of how room requests turn out
+func countRoomRequest(requestType pb.MessageType, result string) {
+	metrics.RoomRequests.WithLabelValues(requestType.String(), result).Inc()
 }
 
 func sendRoomResponse(user *User, messageType pb.MessageType, success bool, roomID rose.RoomID, address string, authtoken []byte) {
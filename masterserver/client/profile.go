@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/profiles"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+const maxDisplayNameLength = 32
+
+func handleGetProfileRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.GetProfileRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendProfileResponse(user, messageType, nil)
+		return err
+	}
+
+	// No user id means our own profile
+	userID := rose.UserID(input.UserId)
+	if userID == 0 {
+		userID = user.ID
+	}
+
+	profile, err := profiles.Get(userID)
+	if err != nil {
+		log.Errorf("Failed to load profile of user %d: %s", userID, err)
+		sendProfileResponse(user, messageType, nil)
+		return nil
+	}
+
+	sendProfileResponse(user, messageType, profile)
+
+	return nil
+}
+
+func handleUpdateProfileRequest(user *User, messageType pb.MessageType, message []byte) error {
+	input := &pb.UpdateProfileRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		sendProfileResponse(user, messageType, nil)
+		return err
+	}
+
+	// Users can only change their own profile, and only the parts that aren't earned
+	profile, err := profiles.Update(user.ID, func(profile *storage.Profile) error {
+		length := utf8.RuneCountInString(input.DisplayName)
+		if length == 0 || length > maxDisplayNameLength {
+			return errors.New("invalid display name")
+		}
+
+		attributes := json.RawMessage(input.Attributes)
+		if len(attributes) == 0 {
+			attributes = json.RawMessage("{}")
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(attributes, &object); err != nil {
+			return errors.New("attributes must be a JSON object")
+		}
+
+		profile.DisplayName = input.DisplayName
+		profile.AvatarID = int(input.AvatarId)
+		profile.Attributes = attributes
+		return nil
+	})
+	if err != nil {
+		log.Warningf("Failed to update profile of user %d: %s", user.ID, err)
+		sendProfileResponse(user, messageType, nil)
+		return nil
+	}
+
+	sendProfileResponse(user, messageType, profile)
+
+	return nil
+}
+
+func sendProfileResponse(user *User, messageType pb.MessageType, profile *storage.Profile) {
+	// Create response
+	response := &pb.ProfileResponse{
+		Success: profile != nil,
+	}
+	if profile != nil {
+		response.Profile = profiles.ToMessage(profile)
+	}
+
+	// Send response
+	user.SendMessage(rose.MessageType(messageType), response)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+var (
+	// shuttingDown set once the master is going away, in unix milliseconds of the deadline
+	shuttingDown int64
+)
+
+// sendShutdown tell the user the master is going away
+func sendShutdown(user rose.User, deadline int64) {
+	user.SendMessage(rose.MessageType(pb.MessageType_ServerShutdown), &pb.ShutdownNotice{
+		Deadline: deadline,
+	})
+}
+
+// Shutdown tell every client the master is going away and wait for them to leave, up to the timeout.
+// Clients connecting in the meantime are told and sent away.
+func Shutdown(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	atomic.StoreInt64(&shuttingDown, deadline.UnixNano()/1e6)
+
+	users := lobby.GetUsers()
+	for _, user := range users {
+		sendShutdown(user, deadline.UnixNano()/1e6)
+	}
+	log.Noticef("Told %d clients we're shutting down", len(users))
+
+	// Give them a chance to leave by themselves
+	for time.Now().Before(deadline) && len(lobby.GetUsers()) > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	users = lobby.GetUsers()
+	if len(users) > 0 {
+		log.Warningf("Disconnecting %d clients that didn't leave in time", len(users))
+	}
+	for _, user := range users {
+		user.Disconnect()
+	}
+}
@@ -1,14 +1,25 @@
 package client
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/chat"
 	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/metrics"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+	"github.com/zeroZshadow/rose-example/shared/storage"
 )
 
 // Logging
-var log = logging.MustGetLogger("global")
+var log = logging.MustGetLogger("client")
+var clientLog = shared.NewLogger("client", nil)
 
 type userMessageHandler func(*User, pb.MessageType, []byte) error
 
@@ -19,6 +30,13 @@ var messageMap = make(map[pb.MessageType]userMessageHandler)
 type User struct {
 	// Framework things
 	*rose.UserBase
+
+	// Rate limits, by kind of action
+	buckets     map[string]*bucket
+	bucketsLock sync.Mutex
+
+	// When the user was let in, zero if they were turned away
+	sessionStart time.Time
 }
 
 // HandlePacket implements rose.User.HandlePacket
@@ -27,8 +45,12 @@ func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
 	messageType := pb.MessageType(msgType)
 
 	// Find handler for message type, run if available
+	user.logger().Debugf("Received %v", messageType)
 	if handler, ok := messageMap[messageType]; ok {
+		metrics.Messages.WithLabelValues("client", messageType.String()).Inc()
+		start := time.Now()
 		err := handler(user, messageType, message)
+		metrics.HandlerDuration.WithLabelValues("client", messageType.String()).Observe(time.Since(start).Seconds())
 		if err != nil {
 			log.Errorf("unmarshaling error: %s\n%v", err, message)
 		}
@@ -39,20 +61,60 @@ func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
 	log.Warningf("Unhandled client message %d!", messageType)
 }
 
+// logger tags log lines with the user
+func (user *User) logger() *shared.Logger {
+	return clientLog.With(shared.Fields{"user_id": user.ID})
+}
+
 // OnDisconnect implements rose.User.OnDisconnect
 func (user *User) OnDisconnect(err error) {
 	lobby.RemoveUser(user.ID)
-	log.Debug("A user disconnected.")
+	chat.LeaveAll(user.ID)
+	social.PresenceChanged(user.ID)
+	metrics.ConnectedClients.Dec()
+	user.logger().Debugf("A user disconnected.")
+
+	// Only users that were let in have a session to end
+	if !user.sessionStart.IsZero() {
+		audit.Record("session.end", audit.User(uint64(user.ID)), "", map[string]string{
+			"duration": time.Since(user.sessionStart).Truncate(time.Second).String(),
+		})
+	}
 }
 
 // OnConnect implements rose.User.OnConnect
 func (user *User) OnConnect() {
-	log.Debug("A user connected.")
+	metrics.ConnectedClients.Inc()
+
+	// No new clients while shutting down
+	if deadline := atomic.LoadInt64(&shuttingDown); deadline != 0 {
+		sendShutdown(user, deadline)
+		user.Disconnect()
+		return
+	}
+
+	// Banned users don't get to play
+	if ban, err := storage.Instance.GetBan(user.ID); err == nil && ban.Active(time.Now()) {
+		user.logger().Noticef("Refused banned user: %s", ban.Reason)
+		audit.RecordLimited("user.banned", audit.User(uint64(user.ID)), "", nil)
+		user.Disconnect()
+		return
+	} else if err != nil && err != storage.ErrNotFound {
+		user.logger().Errorf("Unable to check for a ban, letting them in: %s", err)
+	}
+
+	user.sessionStart = time.Now()
+	audit.Record("session.start", audit.User(uint64(user.ID)), "", nil)
+
+	lobby.SetUser(user)
+	social.PresenceChanged(user.ID)
+	user.logger().Debugf("A user connected.")
 }
 
 // New create a new client.User
 func New(pump *rose.MessagePump) rose.User {
 	return &User{
 		UserBase: rose.NewUserBase(pump),
+		buckets:  make(map[string]*bucket),
 	}
 }
@@ -1,44 +1,195 @@
 package config
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"path/filepath"
+	"flag"
+
+	"github.com/zeroZshadow/rose-example/shared/configload"
 )
 
-//GlobalConfig loaded configuration
-var GlobalConfig *Config
+// current the config in use
+var current configload.Current
+
+// Get the config in use. Don't hold on to it, a reload replaces it.
+func Get() *Config {
+	cfg, _ := current.Get().(*Config)
+	return cfg
+}
+
+// Set the config in use
+func Set(cfg *Config) {
+	current.Set(cfg)
+}
 
 // Config describes the whole process of generating sitemap
+// Fields tagged reload are picked up by Reload, the rest needs a restart.
 type Config struct {
-	Address    string `json:"address"`
-	Database   string `json:"database"`
-	Name       string `json:"name"`
-	VersionKey string `json:"versionkey"`
+	Address         string              `json:"address"`
+	Database        string              `json:"database" secret:"true"`
+	Name            string              `json:"name"`
+	VersionKey      string              `json:"versionkey"`
+	Leaderboards    []LeaderboardConfig `json:"leaderboards"`
+	RatingSystem    string              `json:"ratingsystem" reload:"true"`  // "elo" or "glicko2"
+	RatingModes     map[string]string   `json:"ratingmodes" reload:"true"`   // Rating system per game mode, overrides RatingSystem
+	InviteTimeout   int                 `json:"invitetimeout" reload:"true"` // Seconds
+	InviteRate      float64             `json:"inviterate" reload:"true"`    // Invites per second, 0 disables the limit
+	InviteBurst     int                 `json:"inviteburst" reload:"true"`
+	FriendRate      float64             `json:"friendrate" reload:"true"` // Friend requests per second, 0 disables the limit
+	FriendBurst     int                 `json:"friendburst" reload:"true"`
+	FriendPending   int                 `json:"friendpending" reload:"true"` // Friend requests a user can have waiting at once
+	ChatHistorySize int                 `json:"chathistorysize"`
+	MaxChannels     int                 `json:"maxchannels"`            // Chat channels a user can be in at once
+	ChatRate        float64             `json:"chatrate" reload:"true"` // Whispers and channel messages per second, 0 disables the limit
+	ChatBurst       int                 `json:"chatburst" reload:"true"`
+	AdminAddress    string              `json:"adminaddress"` // Empty disables the admin API and metrics
+	AdminToken      string              `json:"admintoken" secret:"true"`
+	RequiredRegions []string            `json:"requiredregions" reload:"true"` // Regions that need a node before the master is ready
+	ShutdownTimeout int                 `json:"shutdowntimeout" reload:"true"` // Seconds
+
+	configload.Common
+}
+
+// LeaderboardConfig describes a leaderboard, empty region or mode matches all
+type LeaderboardConfig struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	Mode   string `json:"mode"`
+	Score  string `json:"score"` // "score" or "wins"
+	Reset  string `json:"reset"` // "", "daily", "weekly" or "monthly"
 }
 
 // New create new Config with default values
 func New() *Config {
 	return &Config{
 		Address:    ":8080",
-		Database:   "user:password@tcp(localhost:3306)/game_db?charset=utf8&parseTime=true",
+		Database:   "master.db",
 		Name:       "MasterServer1",
 		VersionKey: "demo",
+		Leaderboards: []LeaderboardConfig{
+			{Name: "global", Score: "score"},
+			{Name: "weekly", Score: "wins", Reset: "weekly"},
+		},
+		RatingSystem:    "elo",
+		RatingModes:     map[string]string{},
+		InviteTimeout:   60,
+		InviteRate:      0.5,
+		InviteBurst:     5,
+		FriendRate:      0.1,
+		FriendBurst:     5,
+		FriendPending:   50,
+		ChatHistorySize: 50,
+		MaxChannels:     10,
+		ChatRate:        2,
+		ChatBurst:       10,
+		AdminAddress:    "127.0.0.1:8081",
+		AdminToken:      "",
+		RequiredRegions: []string{},
+		ShutdownTimeout: 30,
+		Common:          configload.DefaultCommon(),
 	}
 }
 
-// FromFile parses file into a Config
-func (cfg *Config) FromFile(file string) error {
-	path, err := filepath.Abs(file)
-	if err != nil {
-		return err
+// envPrefix environment variables overriding the config start with this
+const envPrefix = "MASTER"
+
+// RegisterFlags add a flag for every config field, so they can be overridden from the command line
+func RegisterFlags(flags *flag.FlagSet) {
+	configload.RegisterFlags(flags, New())
+}
+
+// Load the config in layers: defaults, the file if given, environment variables and flags.
+// The result is validated, any problem is returned as an error.
+func Load(file string, flags *flag.FlagSet) (*Config, error) {
+	cfg := New()
+	if err := configload.Load(cfg, file, envPrefix, flags); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
+
+// Reload load the config again and swap in the settings that are safe to change while running.
+// An invalid config is rejected and the current one stays in use.
+func Reload(file string, flags *flag.FlagSet) error {
+	return current.Reload(New(), file, envPrefix, flags)
+}
+
+// Validate check the config makes sense
+func (cfg *Config) Validate() error {
+	var errs configload.Errors
+
+	if cfg.Address == "" {
+		errs.Add("address is required")
+	}
+	if cfg.Database == "" {
+		errs.Add("database is required")
+	}
+
+	names := make(map[string]bool)
+	for i, board := range cfg.Leaderboards {
+		if board.Name == "" {
+			errs.Add("leaderboards[%d]: name is required", i)
+		} else if names[board.Name] {
+			errs.Add("leaderboards[%d]: duplicate name %q", i, board.Name)
+		}
+		names[board.Name] = true
 
-	fileC, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
+		if board.Score != "score" && board.Score != "wins" {
+			errs.Add("leaderboards[%d]: score must be \"score\" or \"wins\", not %q", i, board.Score)
+		}
+		switch board.Reset {
+		case "", "daily", "weekly", "monthly":
+		default:
+			errs.Add("leaderboards[%d]: reset must be empty, \"daily\", \"weekly\" or \"monthly\", not %q", i, board.Reset)
+		}
 	}
 
-	err = json.Unmarshal(fileC, cfg)
-	return err
+	if !validRatingSystem(cfg.RatingSystem) {
+		errs.Add("ratingsystem must be \"elo\" or \"glicko2\", not %q", cfg.RatingSystem)
+	}
+	for mode, system := range cfg.RatingModes {
+		if !validRatingSystem(system) {
+			errs.Add("ratingmodes[%s] must be \"elo\" or \"glicko2\", not %q", mode, system)
+		}
+	}
+
+	if cfg.InviteTimeout <= 0 {
+		errs.Add("invitetimeout must be positive")
+	}
+	if cfg.InviteRate < 0 {
+		errs.Add("inviterate can't be negative")
+	}
+	if cfg.InviteRate > 0 && cfg.InviteBurst < 1 {
+		errs.Add("inviteburst must be at least 1 when inviterate is set")
+	}
+	if cfg.FriendRate < 0 {
+		errs.Add("friendrate can't be negative")
+	}
+	if cfg.FriendRate > 0 && cfg.FriendBurst < 1 {
+		errs.Add("friendburst must be at least 1 when friendrate is set")
+	}
+	if cfg.FriendPending <= 0 {
+		errs.Add("friendpending must be positive")
+	}
+	if cfg.ChatHistorySize < 0 {
+		errs.Add("chathistorysize can't be negative")
+	}
+	if cfg.MaxChannels <= 0 {
+		errs.Add("maxchannels must be positive")
+	}
+	if cfg.ChatRate < 0 {
+		errs.Add("chatrate can't be negative")
+	}
+	if cfg.ChatRate > 0 && cfg.ChatBurst < 1 {
+		errs.Add("chatburst must be at least 1 when chatrate is set")
+	}
+	if cfg.ShutdownTimeout < 0 {
+		errs.Add("shutdowntimeout can't be negative")
+	}
+
+	cfg.Common.Validate(&errs)
+
+	return errs.Err()
+}
+
+func validRatingSystem(name string) bool {
+	return name == "elo" || name == "glicko2"
 }
@@ -0,0 +1,177 @@
+package leaderboard
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/results"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+var log = logging.MustGetLogger("leaderboard")
+
+// Board a ranked leaderboard fed by match results
+type Board struct {
+	config  config.LeaderboardConfig
+	season  string
+	ranking *ranking
+
+	sync.Mutex
+}
+
+var boards = make(map[string]*Board)
+
+// Setup create the configured boards and load the current season of each from storage
+func Setup(configs []config.LeaderboardConfig) error {
+	now := time.Now().UTC()
+
+	for _, cfg := range configs {
+		board := &Board{config: cfg}
+		if err := board.load(seasonAt(cfg.Reset, now)); err != nil {
+			return err
+		}
+
+		boards[cfg.Name] = board
+		log.Infof("Leaderboard %s loaded with %d entries", cfg.Name, board.ranking.Len())
+	}
+
+	return nil
+}
+
+// Get the board with the given name
+func Get(name string) (*Board, bool) {
+	board, ok := boards[name]
+	return board, ok
+}
+
+// seasonAt the season a board with the given reset schedule is in at the given time
+func seasonAt(reset string, now time.Time) string {
+	switch reset {
+	case "daily":
+		return now.Format("2006-01-02")
+	case "weekly":
+		year, week := now.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "monthly":
+		return now.Format("2006-01")
+	default:
+		return "all"
+	}
+}
+
+// load replace the ranking with the given season from storage
+func (board *Board) load(season string) error {
+	scores, err := storage.Instance.GetLeaderboardScores(board.config.Name, season)
+	if err != nil {
+		return err
+	}
+
+	board.season = season
+	board.ranking = newRanking()
+	for _, score := range scores {
+		board.ranking.Set(score.UserID, score.Score)
+	}
+
+	return nil
+}
+
+// rollover start a new season when it is due, must be called with the lock held
+func (board *Board) rollover(now time.Time) {
+	season := seasonAt(board.config.Reset, now)
+	if season == board.season {
+		return
+	}
+
+	log.Noticef("Leaderboard %s starting season %s", board.config.Name, season)
+	board.season = season
+	board.ranking = newRanking()
+}
+
+// Top return up to limit entries of the current season, skipping the first offset entries
+func (board *Board) Top(offset int, limit int) (string, int, []Entry) {
+	board.Lock()
+	defer board.Unlock()
+
+	board.rollover(time.Now().UTC())
+	return board.season, board.ranking.Len(), board.ranking.Range(offset, limit)
+}
+
+// Around return the entries within window ranks of the given user, empty if the user is not ranked
+func (board *Board) Around(userID rose.UserID, window int) (string, int, []Entry) {
+	board.Lock()
+	defer board.Unlock()
+
+	board.rollover(time.Now().UTC())
+
+	rank, ok := board.ranking.Rank(userID)
+	if !ok {
+		return board.season, board.ranking.Len(), []Entry{}
+	}
+
+	offset := rank - 1 - window
+	if offset < 0 {
+		offset = 0
+	}
+	return board.season, board.ranking.Len(), board.ranking.Range(offset, rank+window-offset)
+}
+
+// matches returns true if the result counts towards this board
+func (board *Board) matches(result *results.Result) bool {
+	return (board.config.Region == "" || board.config.Region == result.Region) &&
+		(board.config.Mode == "" || board.config.Mode == result.Mode)
+}
+
+// add the participant's result to their score and persist it
+func (board *Board) add(participant results.Participant, now time.Time) error {
+	board.Lock()
+	defer board.Unlock()
+
+	board.rollover(now)
+
+	var points int64
+	switch board.config.Score {
+	case "wins":
+		if participant.Placement == 1 {
+			points = 1
+		}
+	default:
+		points = participant.Score
+	}
+
+	score, _ := board.ranking.Score(participant.UserID)
+	score += points
+	board.ranking.Set(participant.UserID, score)
+
+	return storage.Instance.SaveLeaderboardScore(&storage.LeaderboardScore{
+		Board:  board.config.Name,
+		Season: board.season,
+		UserID: participant.UserID,
+		Score:  score,
+	})
+}
+
+// ResultSink feeds match results into the leaderboards
+type ResultSink struct{}
+
+// StoreResult implements results.Sink
+func (ResultSink) StoreResult(result *results.Result) error {
+	now := time.Now().UTC()
+
+	for _, board := range boards {
+		if !board.matches(result) {
+			continue
+		}
+
+		for _, participant := range result.Participants {
+			if err := board.add(participant, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package leaderboard
+
+import (
+	"math/rand"
+
+	"github.com/zeroZshadow/rose"
+)
+
+const (
+	maxLevel    = 32
+	levelChance = 0.25
+)
+
+// Entry a user's position on a leaderboard, ranks start at 1
+type Entry struct {
+	UserID rose.UserID
+	Score  int64
+	Rank   int
+}
+
+// ranking keeps users sorted by score, highest first, ties broken by user id.
+// It is a skip list where every link knows how many entries it skips,
+// making lookups by rank and rank lookups by user O(log n).
+// Not safe for concurrent use.
+type ranking struct {
+	head   *rankNode
+	level  int
+	length int
+	scores map[rose.UserID]int64
+}
+
+type rankNode struct {
+	userID rose.UserID
+	score  int64
+	next   []rankLink
+}
+
+type rankLink struct {
+	node *rankNode
+	span int
+}
+
+func newRanking() *ranking {
+	return &ranking{
+		head:   &rankNode{next: make([]rankLink, maxLevel)},
+		level:  1,
+		scores: make(map[rose.UserID]int64),
+	}
+}
+
+// before returns true if the node ranks higher than the given user with the given score
+func (node *rankNode) before(userID rose.UserID, score int64) bool {
+	return node.score > score || (node.score == score && node.userID < userID)
+}
+
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.Float64() < levelChance {
+		level++
+	}
+	return level
+}
+
+// Len number of users on the ranking
+func (r *ranking) Len() int {
+	return r.length
+}
+
+// Score the score of the given user
+func (r *ranking) Score(userID rose.UserID) (int64, bool) {
+	score, ok := r.scores[userID]
+	return score, ok
+}
+
+// Set add the user or change their score
+func (r *ranking) Set(userID rose.UserID, score int64) {
+	if old, ok := r.scores[userID]; ok {
+		if old == score {
+			return
+		}
+		r.remove(userID, old)
+	}
+
+	r.insert(userID, score)
+	r.scores[userID] = score
+}
+
+func (r *ranking) insert(userID rose.UserID, score int64) {
+	var update [maxLevel]*rankNode
+	var rank [maxLevel]int
+
+	// Find the last node before the new one on every level, and its rank
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		if i < r.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i].node != nil && x.next[i].node.before(userID, score) {
+			rank[i] += x.next[i].span
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+
+	// Grow the list if the new node is taller than all others
+	level := randomLevel()
+	if level > r.level {
+		for i := r.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = r.head
+			update[i].next[i].span = r.length
+		}
+		r.level = level
+	}
+
+	// Link the node in and fix up the spans around it
+	node := &rankNode{userID: userID, score: score, next: make([]rankLink, level)}
+	for i := 0; i < level; i++ {
+		node.next[i].node = update[i].next[i].node
+		update[i].next[i].node = node
+
+		node.next[i].span = update[i].next[i].span - (rank[0] - rank[i])
+		update[i].next[i].span = (rank[0] - rank[i]) + 1
+	}
+
+	// Links jumping over the new node skip one more entry
+	for i := level; i < r.level; i++ {
+		update[i].next[i].span++
+	}
+
+	r.length++
+}
+
+func (r *ranking) remove(userID rose.UserID, score int64) {
+	var update [maxLevel]*rankNode
+
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.before(userID, score) {
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+
+	x = x.next[0].node
+	if x == nil || x.userID != userID {
+		return
+	}
+
+	// Unlink the node
+	for i := 0; i < r.level; i++ {
+		if update[i].next[i].node == x {
+			update[i].next[i].span += x.next[i].span - 1
+			update[i].next[i].node = x.next[i].node
+		} else {
+			update[i].next[i].span--
+		}
+	}
+
+	for r.level > 1 && r.head.next[r.level-1].node == nil {
+		r.level--
+	}
+
+	r.length--
+	delete(r.scores, userID)
+}
+
+// Rank the rank of the given user
+func (r *ranking) Rank(userID rose.UserID) (int, bool) {
+	score, ok := r.scores[userID]
+	if !ok {
+		return 0, false
+	}
+
+	rank := 0
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && (x.next[i].node.before(userID, score) || x.next[i].node.userID == userID) {
+			rank += x.next[i].span
+			x = x.next[i].node
+		}
+		if x != r.head && x.userID == userID {
+			return rank, true
+		}
+	}
+
+	return 0, false
+}
+
+// Range return up to limit entries, skipping the first offset entries
+func (r *ranking) Range(offset int, limit int) []Entry {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= r.length || limit <= 0 {
+		return []Entry{}
+	}
+
+	// Walk down to the first requested entry
+	target := offset + 1
+	traversed := 0
+	x := r.head
+	for i := r.level - 1; i >= 0 && traversed < target; i-- {
+		for x.next[i].node != nil && traversed+x.next[i].span <= target {
+			traversed += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+
+	// Then follow the bottom level
+	entries := make([]Entry, 0, limit)
+	for rank := target; x != nil && len(entries) < limit; rank++ {
+		entries = append(entries, Entry{UserID: x.userID, Score: x.score, Rank: rank})
+		x = x.next[0].node
+	}
+
+	return entries
+}
@@ -0,0 +1,95 @@
+package leaderboard
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/zeroZshadow/rose"
+)
+
+func TestRankingOrder(t *testing.T) {
+	r := newRanking()
+	r.Set(1, 10)
+	r.Set(2, 30)
+	r.Set(3, 10)
+	r.Set(4, 20)
+
+	// Ties go to the lowest user id
+	want := []Entry{{2, 30, 1}, {4, 20, 2}, {1, 10, 3}, {3, 10, 4}}
+	got := r.Range(0, 10)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	// Updating a score moves the user
+	r.Set(3, 40)
+	if rank, ok := r.Rank(3); !ok || rank != 1 {
+		t.Errorf("expected user 3 to rank 1st after the update, got %d", rank)
+	}
+	if r.Len() != 4 {
+		t.Errorf("expected the update to keep 4 users, got %d", r.Len())
+	}
+
+	if _, ok := r.Rank(5); ok {
+		t.Error("expected no rank for an unknown user")
+	}
+}
+
+// TestRankingRandom compares the skip list against sorting a plain list
+func TestRankingRandom(t *testing.T) {
+	r := newRanking()
+	scores := make(map[rose.UserID]int64)
+	for i := 0; i < 20000; i++ {
+		userID := rose.UserID(rand.Intn(500))
+		score := int64(rand.Intn(50))
+		r.Set(userID, score)
+		scores[userID] = score
+	}
+
+	expected := make([]Entry, 0, len(scores))
+	for userID, score := range scores {
+		expected = append(expected, Entry{UserID: userID, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score > expected[j].Score
+		}
+		return expected[i].UserID < expected[j].UserID
+	})
+
+	if r.Len() != len(expected) {
+		t.Fatalf("expected %d users, got %d", len(expected), r.Len())
+	}
+	for i, entry := range expected {
+		if rank, ok := r.Rank(entry.UserID); !ok || rank != i+1 {
+			t.Fatalf("expected user %d to rank %d, got %d", entry.UserID, i+1, rank)
+		}
+	}
+
+	const limit = 13
+	for offset := 0; offset < len(expected)+limit; offset += 7 {
+		page := r.Range(offset, limit)
+
+		size := len(expected) - offset
+		if size > limit {
+			size = limit
+		}
+		if size < 0 {
+			size = 0
+		}
+		if len(page) != size {
+			t.Fatalf("offset %d: expected %d entries, got %d", offset, size, len(page))
+		}
+		for i, entry := range page {
+			if entry.UserID != expected[offset+i].UserID || entry.Rank != offset+i+1 {
+				t.Fatalf("offset %d: entry %d is %+v", offset, i, entry)
+			}
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package lobby
 
 import (
+	"sync"
+
 	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/messages/pb"
 )
 
-var log = logging.MustGetLogger("global")
+var log = logging.MustGetLogger("lobby")
 
 type lobby struct {
 	rooms ConcurrentRoomInfoMap
 	users ConcurrentUserMap
+
+	// Which room each user is in, according to the nodes
+	userRooms     map[rose.UserID]rose.RoomID
+	userRoomsLock sync.RWMutex
 }
 
 var (
@@ -19,8 +25,9 @@ var (
 
 func init() {
 	instance = &lobby{
-		rooms: NewRoomInfoMap(),
-		users: NewUserMap(),
+		rooms:     NewRoomInfoMap(),
+		users:     NewUserMap(),
+		userRooms: make(map[rose.UserID]rose.RoomID),
 	}
 }
 
@@ -30,29 +37,87 @@ func GetRoomInfo(id rose.RoomID) (RoomInfo, bool) {
 	return instance.rooms.Get(id)
 }
 
-// SetRoomInfo add/set roominfo in lobby
-func SetRoomInfo(roominfo RoomInfo) {
+// SetRoomInfo add/set roominfo in lobby, returns the users that joined or left the room
+func SetRoomInfo(roominfo RoomInfo) []rose.UserID {
+	old, _ := instance.rooms.Get(roominfo.ID)
+
 	// Add or update room info in map
 	instance.rooms.Set(roominfo.ID, roominfo)
+
+	// Keep track of who moved
+	instance.userRoomsLock.Lock()
+	defer instance.userRoomsLock.Unlock()
+
+	changed := make([]rose.UserID, 0)
+	current := make(map[rose.UserID]bool, len(roominfo.Members))
+	for _, id := range roominfo.Members {
+		current[id] = true
+		if roomID, ok := instance.userRooms[id]; !ok || roomID != roominfo.ID {
+			instance.userRooms[id] = roominfo.ID
+			changed = append(changed, id)
+		}
+	}
+	for _, id := range old.Members {
+		if !current[id] && instance.userRooms[id] == roominfo.ID {
+			delete(instance.userRooms, id)
+			changed = append(changed, id)
+		}
+	}
+
+	return changed
 }
 
-// RemoveRoomInfo remove room from lobby
-func RemoveRoomInfo(id rose.RoomID) {
+// RemoveRoomInfo remove room from lobby, returns the users that were in it
+func RemoveRoomInfo(id rose.RoomID) []rose.UserID {
+	room, ok := instance.rooms.Get(id)
+	if !ok {
+		return nil
+	}
+
 	// Remove room id from map
 	instance.rooms.Remove(id)
+
+	return removeUserRooms(room)
 }
 
-// RemoveRoomsFromNode remove all rooms hosted on given node
-func RemoveRoomsFromNode(node rose.User) {
+// RemoveRoomsFromNode remove all rooms hosted on given node, returns the users that were in them
+func RemoveRoomsFromNode(node rose.User) []rose.UserID {
 	// Slightly nasty, since technically this can cause a race condition!
 	// However, since we only run then when a server is down, we should not get any updates on the rooms that we are going to remove
 	// Remove all rooms associated with the given server
+	users := make([]rose.UserID, 0)
 	for pair := range instance.rooms.IterBuffered() {
 		if pair.Val.Server == node {
 			// Remove room id from map
 			instance.rooms.Remove(pair.Key)
+			users = append(users, removeUserRooms(pair.Val)...)
+		}
+	}
+
+	return users
+}
+
+// removeUserRooms forget the members of a removed room were in it
+func removeUserRooms(room RoomInfo) []rose.UserID {
+	instance.userRoomsLock.Lock()
+	defer instance.userRoomsLock.Unlock()
+
+	for _, id := range room.Members {
+		if instance.userRooms[id] == room.ID {
+			delete(instance.userRooms, id)
 		}
 	}
+
+	return room.Members
+}
+
+// GetUserRoom the room the given user is in
+func GetUserRoom(id rose.UserID) (rose.RoomID, bool) {
+	instance.userRoomsLock.RLock()
+	defer instance.userRoomsLock.RUnlock()
+
+	roomID, ok := instance.userRooms[id]
+	return roomID, ok
 }
 
 // GetAllRooms return all rooms for given region
@@ -64,10 +129,12 @@ func GetAllRooms(region string) []*pb.RoomInfo {
 		// Create RoomInfo to describe the room
 		room := pair.Val
 		info := &pb.RoomInfo{
-			Id:          uint64(room.ID),
-			Name:        room.Name,
-			PlayerCount: int32(room.PlayerCount),
-			PlayerMax:   int32(room.PlayerMax),
+			Id:             uint64(room.ID),
+			Name:           room.Name,
+			PlayerCount:    int32(room.PlayerCount),
+			PlayerMax:      int32(room.PlayerMax),
+			SpectatorCount: int32(room.SpectatorCount),
+			InviteOnly:     room.InviteOnly,
 		}
 
 		// Save description in list
@@ -77,6 +144,26 @@ func GetAllRooms(region string) []*pb.RoomInfo {
 	return rooms
 }
 
+// GetRooms return info on every room in the lobby
+func GetRooms() []RoomInfo {
+	rooms := make([]RoomInfo, 0, instance.rooms.Count())
+	for pair := range instance.rooms.IterBuffered() {
+		rooms = append(rooms, pair.Val)
+	}
+
+	return rooms
+}
+
+// GetUsers return every connected user
+func GetUsers() []rose.User {
+	users := make([]rose.User, 0, instance.users.Count())
+	for pair := range instance.users.IterBuffered() {
+		users = append(users, pair.Val)
+	}
+
+	return users
+}
+
 // SetUser Add the user to the concurrent map
 func SetUser(user rose.User) {
 	// Add or update user in map
@@ -2,13 +2,25 @@ package lobby
 
 import "github.com/zeroZshadow/rose"
 
+// StatePlaying the state nodes report for rooms playing a match
+const StatePlaying = 1
+
 // RoomInfo representing a room on a node
 type RoomInfo struct {
-	ID          rose.RoomID
-	Name        string
-	PlayerCount int
-	PlayerMax   int
-	State       int
+	ID             rose.RoomID
+	Name           string
+	PlayerCount    int
+	PlayerMax      int
+	SpectatorCount int
+	State          int
+	Members        []rose.UserID
+	InviteOnly     bool
+
+	// MatchPlayers the members when the match started, only they can have results
+	MatchPlayers []rose.UserID
+
+	// ResultReported the node already reported the match played in this room
+	ResultReported bool
 
 	Server rose.User
 }
@@ -0,0 +1,48 @@
+// Package metrics holds the Prometheus metrics of the master server
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ConnectedClients number of clients connected to the master
+	ConnectedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "master",
+		Name:      "connected_clients",
+		Help:      "Number of clients connected to the master.",
+	})
+
+	// RoomRequests room creations and joins handed out, by request type and result
+	RoomRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "master",
+		Name:      "room_requests_total",
+		Help:      "Requests to create or join a room, by request type and result.",
+	}, []string{"request", "result"})
+
+	// Messages received, by sender and message type
+	Messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "master",
+		Name:      "messages_total",
+		Help:      "Messages received, by sender (client or node) and message type.",
+	}, []string{"source", "type"})
+
+	// HandlerDuration time spent handling messages, by sender and message type
+	HandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "master",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent handling a message, by sender (client or node) and message type.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"source", "type"})
+
+	// ResultsDropped match results lost because the result queue was full
+	ResultsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "master",
+		Name:      "results_dropped_total",
+		Help:      "Match results dropped because the queue to storage was full.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ConnectedClients, RoomRequests, Messages, HandlerDuration, ResultsDropped)
+}
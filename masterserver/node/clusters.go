@@ -2,6 +2,9 @@ package node
 
 import (
 	"sync"
+	"time"
+
+	"github.com/zeroZshadow/rose"
 )
 
 // ClusterMap collection of nodes connected to master server
@@ -61,7 +64,7 @@ func (clusterMap *ClusterMap) GetAllNodes() []*User {
 
 	nodeCount := len(clusterMap.nodes)
 	if nodeCount > 0 {
-		nodes := make([]*User, 0, len(clusterMap.nodes))
+		nodes := make([]*User, nodeCount)
 		copy(nodes, clusterMap.nodes)
 		return nodes
 	}
@@ -77,9 +80,9 @@ func (clusterMap *ClusterMap) GetBestForRegion(region string) *User {
 	// Only succeed if we have nodes
 	var bestNode *User
 
-	// Iterate over nodes to get the matching region with the least rooms
+	// Iterate over nodes to get the matching region with the least rooms, draining nodes take no new rooms
 	for _, node := range clusterMap.nodes {
-		if node.Region == region {
+		if node.Region == region && !node.Draining {
 			if bestNode == nil || node.RoomCount < bestNode.RoomCount {
 				bestNode = node
 			}
@@ -88,3 +91,80 @@ func (clusterMap *ClusterMap) GetBestForRegion(region string) *User {
 
 	return bestNode
 }
+
+// GetNode return the node with the given id
+func (clusterMap *ClusterMap) GetNode(id rose.UserID) *User {
+	clusterMap.RLock()
+	defer clusterMap.RUnlock()
+
+	for _, node := range clusterMap.nodes {
+		if node.ID == id {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// Status snapshot of a node, safe to use outside the cluster lock
+type Status struct {
+	ID         rose.UserID
+	Address    string
+	Region     string
+	RoomCount  int
+	RoomMax    int
+	Registered bool
+	Draining   bool
+	Connected  time.Time
+	LastSeen   time.Time
+}
+
+// GetStatus return a snapshot of every node in the cluster
+func (clusterMap *ClusterMap) GetStatus() []Status {
+	clusterMap.RLock()
+	defer clusterMap.RUnlock()
+
+	status := make([]Status, 0, len(clusterMap.nodes))
+	for _, node := range clusterMap.nodes {
+		status = append(status, Status{
+			ID:         node.ID,
+			Address:    node.Address,
+			Region:     node.Region,
+			RoomCount:  node.RoomCount,
+			RoomMax:    node.RoomMax,
+			Registered: node.CipherKey != nil,
+			Draining:   node.Draining,
+			Connected:  node.Connected,
+			LastSeen:   node.LastSeen,
+		})
+	}
+
+	return status
+}
+
+// SetDraining stop or resume placing new rooms on the node
+func (clusterMap *ClusterMap) SetDraining(node *User, draining bool) {
+	clusterMap.Lock()
+	defer clusterMap.Unlock()
+
+	node.Draining = draining
+}
+
+// addRooms adjust the number of rooms hosted on the node
+func (clusterMap *ClusterMap) addRooms(node *User, delta int) {
+	clusterMap.Lock()
+	defer clusterMap.Unlock()
+
+	node.RoomCount += delta
+	if node.RoomCount < 0 {
+		node.RoomCount = 0
+	}
+}
+
+// seen mark the node as alive
+func (clusterMap *ClusterMap) seen(node *User) {
+	clusterMap.Lock()
+	defer clusterMap.Unlock()
+
+	node.LastSeen = time.Now()
+}
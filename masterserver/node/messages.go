@@ -3,23 +3,49 @@ package node
 // A node is a gameserver. This logic is for internal things, has nothing to do with the client.
 
 import (
+	"crypto/aes"
+	"fmt"
+	"time"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/results"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+	"github.com/zeroZshadow/rose-example/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = tracing.Tracer("masterserver/node")
+
 // SetupMessageHandlers Fill the message map for the client
 func SetupMessageHandlers() {
 	messageMap[pb.MessageType_RegisterNode] = handleRegisterNode // Not to be confused with the Client's handleRegisterAccount
 	messageMap[pb.MessageType_UpdateRoom] = handleUpdateRoom
+	messageMap[pb.MessageType_MatchResult] = handleMatchResult
 }
 
 func handleRegisterNode(user *User, messageType pb.MessageType, message []byte) {
 	input := &pb.RegisterNodeRequest{}
 	err := proto.Unmarshal(message, input)
 	if err != nil {
-		log.Errorf("unmarshaling error: %s", err)
+		user.logger().Errorf("unmarshaling error: %s", err)
+		audit.Record("node.reject", audit.Node(uint64(user.ID)), "", map[string]string{"reason": err.Error()})
+		return
+	}
+
+	// A node we can't make tokens for would fail every room request sent to it
+	if _, err := aes.NewCipher(input.Cipher); err != nil {
+		user.logger().Warningf("Rejected node: %s", err)
+		audit.Record("node.reject", audit.Node(uint64(user.ID)), "", map[string]string{
+			"address": input.Address,
+			"region":  input.Region,
+			"reason":  err.Error(),
+		})
+		user.SendMessage(rose.MessageType(messageType), &pb.RegisterNodeResponse{Success: false})
+		user.Disconnect()
 		return
 	}
 
@@ -28,18 +54,33 @@ func handleRegisterNode(user *User, messageType pb.MessageType, message []byte)
 	user.CipherKey = input.Cipher
 	user.Address = input.Address
 
-	log.Noticef("Node %d serving at %s for region %s", user.ID, user.Address, user.Region)
+	user.logger().Noticef("Node serving at %s for region %s", user.Address, user.Region)
+	user.SendMessage(rose.MessageType(messageType), &pb.RegisterNodeResponse{Success: true})
+	audit.Record("node.register", audit.Node(uint64(user.ID)), "", map[string]string{
+		"address": user.Address,
+		"region":  user.Region,
+	})
 }
 
 func handleUpdateRoom(user *User, messageType pb.MessageType, message []byte) {
 	input := &pb.UpdateRoomRequest{}
 	err := proto.Unmarshal(message, input)
 	if err != nil {
-		log.Errorf("unmarshaling error: %s", err)
+		user.logger().Errorf("unmarshaling error: %s", err)
 		return
 	}
 
+	// Updates caused by a traced room request are part of that trace
+	_, span := tracer.Start(tracing.Extract(input.TraceParent), "UpdateRoom")
+	span.SetAttributes(
+		attribute.Int64("node.id", int64(user.ID)),
+		attribute.Int64("room.id", int64(input.GetRoom().GetId())),
+		attribute.Bool("remove", input.Remove),
+	)
+	defer span.End()
+
 	// Check of room exists
+	var moved []rose.UserID
 	inputroom := input.GetRoom()
 	room, ok := lobby.GetRoomInfo(rose.RoomID(inputroom.Id))
 	if ok {
@@ -47,28 +88,113 @@ func handleUpdateRoom(user *User, messageType pb.MessageType, message []byte) {
 		// Do we have to remove the room?
 		if input.Remove {
 			// Remove room from lobby
-			lobby.RemoveRoomInfo(rose.RoomID(inputroom.Id))
+			user.logger().Infof("Room %d closed, reason: %s", inputroom.Id, input.Reason)
+			moved = lobby.RemoveRoomInfo(rose.RoomID(inputroom.Id))
+			Cluster.addRooms(user, -1)
 		} else {
 			// Update room info
 			room.Name = inputroom.Name
 			room.PlayerCount = int(inputroom.PlayerCount)
 			room.PlayerMax = int(inputroom.PlayerMax)
+			room.SpectatorCount = int(inputroom.SpectatorCount)
+			room.Members = userIDs(inputroom.Members)
+
+			// Remember who played from the start, leaving doesn't take them out of the match
+			if room.State != lobby.StatePlaying && int(inputroom.State) == lobby.StatePlaying {
+				room.MatchPlayers = room.Members
+			}
 			room.State = int(inputroom.State)
 
-			lobby.SetRoomInfo(room)
+			moved = lobby.SetRoomInfo(room)
 		}
-	} else {
+	} else if !input.Remove {
 		// Else create the room
 		room = lobby.RoomInfo{
-			ID:          rose.RoomID(inputroom.Id),
-			Name:        inputroom.Name,
-			PlayerCount: int(inputroom.PlayerCount),
-			PlayerMax:   int(inputroom.PlayerMax),
-			State:       int(inputroom.State),
-			Server:      user,
+			ID:             rose.RoomID(inputroom.Id),
+			Name:           inputroom.Name,
+			PlayerCount:    int(inputroom.PlayerCount),
+			PlayerMax:      int(inputroom.PlayerMax),
+			SpectatorCount: int(inputroom.SpectatorCount),
+			State:          int(inputroom.State),
+			Members:        userIDs(inputroom.Members),
+			InviteOnly:     inputroom.InviteOnly,
+			Server:         user,
 		}
 
 		// Add room to lobby
-		lobby.SetRoomInfo(room)
+		moved = lobby.SetRoomInfo(room)
+		Cluster.addRooms(user, 1)
+	}
+
+	// Let friends know where the users that joined or left are now
+	for _, id := range moved {
+		social.PresenceChanged(id)
+	}
+}
+
+func userIDs(ids []uint64) []rose.UserID {
+	result := make([]rose.UserID, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, rose.UserID(id))
+	}
+	return result
+}
+
+func handleMatchResult(user *User, messageType pb.MessageType, message []byte) {
+	input := &pb.MatchResultRequest{}
+	err := proto.Unmarshal(message, input)
+	if err != nil {
+		user.logger().Errorf("unmarshaling error: %s", err)
+		return
+	}
+
+	// Only the node hosting the room may report its results
+	room, ok := lobby.GetRoomInfo(rose.RoomID(input.RoomId))
+	if !ok || room.Server != user {
+		user.logger().Warningf("Node reported a result for room %d it does not host", input.RoomId)
+		return
+	}
+
+	// A room plays a single match, counting it twice would skew ratings and leaderboards
+	if room.ResultReported {
+		user.logger().Warningf("Node reported a second result for room %d", input.RoomId)
+		return
+	}
+
+	// Only players that were in the room when the match started can have played in it, each of them once
+	if err := checkParticipants(room, input.Participants); err != nil {
+		user.logger().Warningf("Rejected the result for room %d: %s", input.RoomId, err)
+		return
+	}
+
+	room.ResultReported = true
+	lobby.SetRoomInfo(room)
+
+	// Hand the result over to whoever is interested, Submit logs and counts results it has to drop
+	results.Submit(results.FromMessage(input, user.Region, user.ID, time.Now()))
+}
+
+// checkParticipants returns an error if a participant wasn't in the room when the match started, or is listed twice
+func checkParticipants(room lobby.RoomInfo, participants []*pb.MatchParticipant) error {
+	if len(room.MatchPlayers) == 0 {
+		return fmt.Errorf("no match was started")
+	}
+
+	members := make(map[rose.UserID]bool, len(room.MatchPlayers))
+	for _, id := range room.MatchPlayers {
+		members[id] = true
+	}
+
+	seen := make(map[rose.UserID]bool, len(participants))
+	for _, participant := range participants {
+		id := rose.UserID(participant.UserId)
+		if !members[id] {
+			return fmt.Errorf("user %d was not in the room when the match started", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("user %d is listed twice", id)
+		}
+		seen[id] = true
 	}
+	return nil
 }
@@ -5,18 +5,21 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"io"
+	"time"
 
-	"github.com/op/go-logging"
 	"github.com/zeroZshadow/rose"
 	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/masterserver/metrics"
+	"github.com/zeroZshadow/rose-example/masterserver/social"
 	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
 )
 
 type userMessageHandler func(*User, pb.MessageType, []byte)
 
 // MessageMap Map of messageType handlers
 var messageMap = make(map[pb.MessageType]userMessageHandler)
-var log = logging.MustGetLogger("global")
+var nodeLog = shared.NewLogger("node", nil)
 
 // User is a connected game server
 type User struct {
@@ -30,32 +33,52 @@ type User struct {
 	RoomCount   int
 	RoomMax     int
 	CipherKey   []byte
+	Draining    bool
+	Connected   time.Time
+	LastSeen    time.Time
 }
 
 // HandlePacket implements User.HandlePacket
 func (user *User) HandlePacket(msgType rose.MessageType, message []byte) {
 	//Convert to pb
 	messageType := pb.MessageType(msgType)
+	Cluster.seen(user)
+	user.logger().Debugf("Received %v", messageType)
 
 	// Find handler for message type, run if available
 	if handler, ok := messageMap[messageType]; ok {
+		metrics.Messages.WithLabelValues("node", messageType.String()).Inc()
+		start := time.Now()
 		handler(user, messageType, message)
+		metrics.HandlerDuration.WithLabelValues("node", messageType.String()).Observe(time.Since(start).Seconds())
 		return
 	}
 
 	// No handler found
-	log.Warningf("Unhandled node message %d!", messageType)
+	user.logger().Warningf("Unhandled node message %d!", messageType)
+}
+
+// logger tags log lines with the node
+func (user *User) logger() *shared.Logger {
+	return nodeLog.With(shared.Fields{"node_id": user.ID, "region": user.Region})
 }
 
 // OnDisconnect implements User.OnDisconnect
 func (user *User) OnDisconnect(err error) {
 	// Remove us from the list of active nodes
 	Cluster.RemoveNode(user)
-	lobby.RemoveRoomsFromNode(user)
+
+	// Everyone playing on this node is back in the lobby
+	for _, id := range lobby.RemoveRoomsFromNode(user) {
+		social.PresenceChanged(id)
+	}
 }
 
 // OnConnect implements User.OnConnect
 func (user *User) OnConnect() {
+	user.Connected = time.Now()
+	user.LastSeen = user.Connected
+
 	// Register new cluster in DB
 	id := Cluster.AddNode(user)
 	// Change to string
@@ -86,6 +109,31 @@ func (user *User) Encrypt(data []byte) ([]byte, error) {
 	return cipherText, nil
 }
 
+// Drain stop or resume placing new rooms on the node, and let the node know
+func (user *User) Drain(draining bool) {
+	Cluster.SetDraining(user, draining)
+	user.SendMessage(rose.MessageType(pb.MessageType_DrainNode), &pb.DrainNodeRequest{Drain: draining})
+
+	user.logger().Noticef("Node draining: %t", draining)
+}
+
+// CloseRoom ask the node to close one of its rooms
+func (user *User) CloseRoom(roomID rose.RoomID, reason pb.RoomCloseReason) {
+	user.SendMessage(rose.MessageType(pb.MessageType_CloseRoom), &pb.CloseRoomRequest{
+		Id:     uint64(roomID),
+		Reason: reason,
+	})
+}
+
+// KickUser ask the node to remove a user from one of its rooms
+func (user *User) KickUser(roomID rose.RoomID, userID rose.UserID, reason string) {
+	user.SendMessage(rose.MessageType(pb.MessageType_KickUser), &pb.KickUserRequest{
+		RoomId: uint64(roomID),
+		UserId: uint64(userID),
+		Reason: reason,
+	})
+}
+
 // New Create new node.User
 func New(pump *rose.MessagePump) rose.User {
 	return &User{
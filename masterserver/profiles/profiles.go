@@ -0,0 +1,97 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/results"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+// Guards read-modify-write cycles on profiles
+var lock sync.Mutex
+
+// Default the profile of a user that never saved one
+func Default(userID rose.UserID) *storage.Profile {
+	return &storage.Profile{
+		UserID:      userID,
+		DisplayName: fmt.Sprintf("Player %d", userID),
+		Level:       1,
+		Attributes:  json.RawMessage("{}"),
+	}
+}
+
+// Get the profile of the given user
+func Get(userID rose.UserID) (*storage.Profile, error) {
+	profile, err := storage.Instance.GetProfile(userID)
+	if err == storage.ErrNotFound {
+		return Default(userID), nil
+	}
+	return profile, err
+}
+
+// Update apply the change to the profile of the given user and save it
+func Update(userID rose.UserID, change func(profile *storage.Profile) error) (*storage.Profile, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	profile, err := Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := change(profile); err != nil {
+		return nil, err
+	}
+
+	profile.Updated = time.Now().UTC()
+	return profile, storage.Instance.SaveProfile(profile)
+}
+
+// ToMessage convert a profile into its protobuf representation
+func ToMessage(profile *storage.Profile) *pb.PlayerProfile {
+	return &pb.PlayerProfile{
+		UserId:      uint64(profile.UserID),
+		DisplayName: profile.DisplayName,
+		AvatarId:    int32(profile.AvatarID),
+		Level:       int32(profile.Level),
+		Attributes:  string(profile.Attributes),
+		GamesPlayed: int32(profile.GamesPlayed),
+		Wins:        int32(profile.Wins),
+	}
+}
+
+// ToShared the part of the profile that is passed along to the gameserver
+func ToShared(profile *storage.Profile) shared.PlayerProfile {
+	return shared.PlayerProfile{
+		DisplayName: profile.DisplayName,
+		AvatarID:    profile.AvatarID,
+		Level:       profile.Level,
+	}
+}
+
+// ResultSink counts games played and wins from match results
+type ResultSink struct{}
+
+// StoreResult implements results.Sink
+func (ResultSink) StoreResult(result *results.Result) error {
+	for _, participant := range result.Participants {
+		_, err := Update(participant.UserID, func(profile *storage.Profile) error {
+			profile.GamesPlayed++
+			if participant.Placement == 1 {
+				profile.Wins++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package rating
+
+import (
+	"math"
+
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+const eloK = 32.0
+
+// updateElo classic Elo, the K factor is shared between all opponents of the match
+func updateElo(player *storage.Rating, opponents []opponent) {
+	k := eloK / float64(len(opponents))
+
+	delta := 0.0
+	for _, opponent := range opponents {
+		expected := 1 / (1 + math.Pow(10, (opponent.rating-player.Rating)/400))
+		delta += k * (opponent.score - expected)
+	}
+
+	player.Rating += delta
+}
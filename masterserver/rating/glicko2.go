@@ -0,0 +1,86 @@
+package rating
+
+import (
+	"math"
+
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+const (
+	glickoScale     = 173.7178
+	glickoTau       = 0.5
+	glickoTolerance = 0.000001
+)
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoE(mu float64, muJ float64, phiJ float64) float64 {
+	return 1 / (1 + math.Exp(-glickoG(phiJ)*(mu-muJ)))
+}
+
+// updateGlicko2 Glicko-2, treating the match as a single rating period
+func updateGlicko2(player *storage.Rating, opponents []opponent) {
+	// Convert to the Glicko-2 scale
+	mu := (player.Rating - initialRating) / glickoScale
+	phi := player.Deviation / glickoScale
+	sigma := player.Volatility
+
+	// Estimated variance and improvement
+	var variance, improvement float64
+	for _, opponent := range opponents {
+		muJ := (opponent.rating - initialRating) / glickoScale
+		phiJ := opponent.deviation / glickoScale
+		g := glickoG(phiJ)
+		e := glickoE(mu, muJ, phiJ)
+
+		variance += g * g * e * (1 - e)
+		improvement += g * (opponent.score - e)
+	}
+	v := 1 / variance
+	delta := v * improvement
+
+	// New volatility, using the Illinois algorithm
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoTolerance {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma = math.Exp(A / 2)
+
+	// New deviation and rating
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * improvement
+
+	// Back to the Glicko scale
+	player.Rating = mu*glickoScale + initialRating
+	player.Deviation = phi * glickoScale
+	player.Volatility = sigma
+}
@@ -0,0 +1,176 @@
+package rating
+
+import (
+	"math"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/results"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+var log = logging.MustGetLogger("rating")
+
+// Starting values for new players
+const (
+	initialRating     = 1500.0
+	initialDeviation  = 350.0
+	initialVolatility = 0.06
+)
+
+// Guards read-modify-write cycles on ratings
+var lock sync.Mutex
+
+// opponent the combined rating of a team a player played against,
+// score is 1 for a win, 0.5 for a draw and 0 for a loss
+type opponent struct {
+	rating    float64
+	deviation float64
+	score     float64
+}
+
+// system computes a player's new rating from the opponents faced in a match
+type system func(player *storage.Rating, opponents []opponent)
+
+// systemFor the rating system configured for the given game mode
+func systemFor(mode string) system {
+	cfg := config.Get()
+	name, ok := cfg.RatingModes[mode]
+	if !ok {
+		name = cfg.RatingSystem
+	}
+
+	switch name {
+	case "glicko2":
+		return updateGlicko2
+	default:
+		return updateElo
+	}
+}
+
+// Get the current rating of a user in the given game mode, users without one start at the initial rating
+func Get(userID rose.UserID, mode string) (*storage.Rating, error) {
+	rating, err := storage.Instance.GetRating(userID, mode)
+	if err == storage.ErrNotFound {
+		return &storage.Rating{
+			UserID:     userID,
+			Mode:       mode,
+			Rating:     initialRating,
+			Deviation:  initialDeviation,
+			Volatility: initialVolatility,
+		}, nil
+	}
+	return rating, err
+}
+
+// team participants sharing a team, ranked by their best placement
+type team struct {
+	placement int
+	members   []*storage.Rating
+}
+
+// combined the average rating and root mean square deviation of the team
+func (t *team) combined() (float64, float64) {
+	var rating, variance float64
+	for _, member := range t.members {
+		rating += member.Rating
+		variance += member.Deviation * member.Deviation
+	}
+
+	count := float64(len(t.members))
+	return rating / count, math.Sqrt(variance / count)
+}
+
+// Update rate all participants of a match against the teams they played against
+func Update(result *results.Result) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Group participants into teams, players without a team play alone
+	teams := make([]*team, 0)
+	byID := make(map[int]*team)
+	for _, participant := range result.Participants {
+		rating, err := Get(participant.UserID, result.Mode)
+		if err != nil {
+			return err
+		}
+
+		t, ok := byID[participant.Team]
+		if !ok || participant.Team == 0 {
+			t = &team{placement: participant.Placement}
+			teams = append(teams, t)
+			if participant.Team != 0 {
+				byID[participant.Team] = t
+			}
+		}
+
+		if participant.Placement < t.placement {
+			t.placement = participant.Placement
+		}
+		t.members = append(t.members, rating)
+	}
+
+	if len(teams) < 2 {
+		return nil
+	}
+
+	// Every team played against every other team, using the ratings from before the match
+	opponents := make([][]opponent, len(teams))
+	for i, t := range teams {
+		for j, other := range teams {
+			if i == j {
+				continue
+			}
+
+			rating, deviation := other.combined()
+			score := 0.5
+			if t.placement < other.placement {
+				score = 1
+			} else if t.placement > other.placement {
+				score = 0
+			}
+
+			opponents[i] = append(opponents[i], opponent{rating: rating, deviation: deviation, score: score})
+		}
+	}
+
+	// Apply and record the new ratings
+	update := systemFor(result.Mode)
+	for i, t := range teams {
+		for _, member := range t.members {
+			before := member.Rating
+			update(member, opponents[i])
+			member.Games++
+			member.Updated = result.End
+
+			change := &storage.RatingChange{
+				UserID:  member.UserID,
+				Mode:    result.Mode,
+				MatchID: result.RoomID,
+				Before:  before,
+				After:   member.Rating,
+				Time:    result.End,
+			}
+			if err := storage.Instance.SaveRating(member, change); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// History the most recent rating changes of a user in the given game mode
+func History(userID rose.UserID, mode string, limit int) ([]storage.RatingChange, error) {
+	return storage.Instance.GetRatingHistory(userID, mode, limit)
+}
+
+// ResultSink updates ratings from match results
+type ResultSink struct{}
+
+// StoreResult implements results.Sink
+func (ResultSink) StoreResult(result *results.Result) error {
+	return Update(result)
+}
@@ -0,0 +1,51 @@
+package rating
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+func TestElo(t *testing.T) {
+	// Equal ratings move by half the K factor
+	player := &storage.Rating{Rating: 1500}
+	updateElo(player, []opponent{{rating: 1500, score: 1}})
+	if player.Rating != 1500+eloK/2 {
+		t.Errorf("expected %v after beating an equal opponent, got %v", 1500+eloK/2, player.Rating)
+	}
+
+	// Beating a much weaker opponent is worth little
+	player = &storage.Rating{Rating: 1900}
+	updateElo(player, []opponent{{rating: 1500, score: 1}})
+	if gain := player.Rating - 1900; gain <= 0 || gain > 3 {
+		t.Errorf("expected a small gain after beating a weaker opponent, got %v", gain)
+	}
+
+	// A draw between equals changes nothing
+	player = &storage.Rating{Rating: 1500}
+	updateElo(player, []opponent{{rating: 1500, score: 0.5}, {rating: 1500, score: 0.5}})
+	if player.Rating != 1500 {
+		t.Errorf("expected no change after a draw, got %v", player.Rating)
+	}
+}
+
+// TestGlicko2 the example from Glickman's description of the Glicko-2 system
+func TestGlicko2(t *testing.T) {
+	player := &storage.Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	updateGlicko2(player, []opponent{
+		{rating: 1400, deviation: 30, score: 1},
+		{rating: 1550, deviation: 100, score: 0},
+		{rating: 1700, deviation: 300, score: 0},
+	})
+
+	if math.Abs(player.Rating-1464.06) > 0.1 {
+		t.Errorf("expected a rating of 1464.06, got %v", player.Rating)
+	}
+	if math.Abs(player.Deviation-151.52) > 0.1 {
+		t.Errorf("expected a deviation of 151.52, got %v", player.Deviation)
+	}
+	if math.Abs(player.Volatility-0.05999) > 0.0001 {
+		t.Errorf("expected a volatility of 0.05999, got %v", player.Volatility)
+	}
+}
@@ -0,0 +1,121 @@
+package results
+
+import (
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/metrics"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+var log = logging.MustGetLogger("results")
+
+// Participant the outcome of a match for a single player
+type Participant struct {
+	UserID    rose.UserID
+	Team      int
+	Placement int
+	Score     int64
+	Stats     map[string]string
+}
+
+// Result a finished match as reported by the node hosting it
+type Result struct {
+	RoomID       rose.RoomID
+	Mode         string
+	Region       string
+	NodeID       rose.UserID
+	Participants []Participant
+	Stats        map[string]string
+	Start        time.Time
+	End          time.Time
+}
+
+// Sink receives validated match results, for example to persist them
+type Sink interface {
+	StoreResult(result *Result) error
+}
+
+// queueSize results waiting for the sinks before new ones are dropped
+const queueSize = 256
+
+var (
+	sinks     []Sink
+	sinksLock sync.RWMutex
+
+	// Sinks may be slow, results are handed to them by a single worker
+	queue     = make(chan *Result, queueSize)
+	startOnce sync.Once
+)
+
+// AddSink register a sink that will receive all future match results
+func AddSink(sink Sink) {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	sinks = append(sinks, sink)
+}
+
+// Submit queue a match result for all registered sinks, returns false if the queue is full
+func Submit(result *Result) bool {
+	startOnce.Do(func() {
+		go work()
+	})
+
+	select {
+	case queue <- result:
+		return true
+	default:
+		log.Errorf("Result queue is full, dropped match result for room %d", result.RoomID)
+		metrics.ResultsDropped.Inc()
+		return false
+	}
+}
+
+// work hand queued results to the sinks, one at a time so sinks see them in order
+func work() {
+	for result := range queue {
+		store(result)
+	}
+}
+
+// store hand a match result to all registered sinks
+func store(result *Result) {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+
+	log.Infof("Match result for room %d (%s) with %d participants", result.RoomID, result.Mode, len(result.Participants))
+
+	for _, sink := range sinks {
+		if err := sink.StoreResult(result); err != nil {
+			log.Errorf("Failed to store match result for room %d: %s", result.RoomID, err)
+		}
+	}
+}
+
+// FromMessage convert a MatchResult message received at the given time into a Result
+func FromMessage(message *pb.MatchResultRequest, region string, nodeID rose.UserID, received time.Time) *Result {
+	participants := make([]Participant, 0, len(message.Participants))
+	for _, participant := range message.Participants {
+		participants = append(participants, Participant{
+			UserID:    rose.UserID(participant.UserId),
+			Team:      int(participant.Team),
+			Placement: int(participant.Placement),
+			Score:     participant.Score,
+			Stats:     participant.Stats,
+		})
+	}
+
+	return &Result{
+		RoomID:       rose.RoomID(message.RoomId),
+		Mode:         message.Mode,
+		Region:       region,
+		NodeID:       nodeID,
+		Participants: participants,
+		Stats:        message.Stats,
+		Start:        received.Add(-time.Duration(message.Duration) * time.Millisecond),
+		End:          received,
+	}
+}
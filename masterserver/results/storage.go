@@ -0,0 +1,64 @@
+package results
+
+import (
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+// StorageSink keeps every finished match in the match history
+type StorageSink struct{}
+
+// StoreResult implements Sink
+func (StorageSink) StoreResult(result *Result) error {
+	return storage.Instance.SaveMatch(ToMatch(result))
+}
+
+// ToMatch convert a result into a match history entry, the room id doubles as match id
+func ToMatch(result *Result) *storage.Match {
+	participants := make([]storage.MatchParticipant, 0, len(result.Participants))
+	for _, participant := range result.Participants {
+		participants = append(participants, storage.MatchParticipant{
+			UserID:    participant.UserID,
+			Team:      participant.Team,
+			Placement: participant.Placement,
+			Score:     participant.Score,
+			Stats:     participant.Stats,
+		})
+	}
+
+	return &storage.Match{
+		ID:           result.RoomID,
+		Mode:         result.Mode,
+		Region:       result.Region,
+		NodeID:       result.NodeID,
+		Participants: participants,
+		Stats:        result.Stats,
+		Start:        result.Start,
+		End:          result.End,
+	}
+}
+
+// MatchToMessage convert a stored match into its protobuf representation
+func MatchToMessage(match *storage.Match) *pb.MatchInfo {
+	participants := make([]*pb.MatchParticipant, 0, len(match.Participants))
+	for _, participant := range match.Participants {
+		participants = append(participants, &pb.MatchParticipant{
+			UserId:    uint64(participant.UserID),
+			Team:      int32(participant.Team),
+			Placement: int32(participant.Placement),
+			Score:     participant.Score,
+			Stats:     participant.Stats,
+		})
+	}
+
+	return &pb.MatchInfo{
+		Id:           uint64(match.ID),
+		Mode:         match.Mode,
+		Region:       match.Region,
+		NodeId:       uint64(match.NodeID),
+		Participants: participants,
+		Stats:        match.Stats,
+		StartTime:    match.Start.UnixNano() / 1e6,
+		EndTime:      match.End.UnixNano() / 1e6,
+	}
+}
@@ -0,0 +1,294 @@
+package social
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/config"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+var log = logging.MustGetLogger("social")
+
+var (
+	// ErrSelf users can't befriend or block themselves
+	ErrSelf = errors.New("social: can't target yourself")
+	// ErrBlocked the other user blocked you, or you blocked them
+	ErrBlocked = errors.New("social: blocked")
+	// ErrAlreadyFriends the users are friends already
+	ErrAlreadyFriends = errors.New("social: already friends")
+	// ErrNoRequest there is no pending friend request
+	ErrNoRequest = errors.New("social: no pending request")
+	// ErrUnknownUser the other user never played here
+	ErrUnknownUser = errors.New("social: unknown user")
+	// ErrTooManyRequests the user has too many friend requests waiting for an answer
+	ErrTooManyRequests = errors.New("social: too many pending requests")
+)
+
+// Guards changes to relations, which often touch both directions
+var lock sync.Mutex
+
+// relationState the state of the relation of userID towards otherID, empty if there is none
+func relationState(userID rose.UserID, otherID rose.UserID) (storage.RelationState, error) {
+	relation, err := storage.Instance.GetRelation(userID, otherID)
+	if err == storage.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return relation.State, nil
+}
+
+// Blocked returns true if the user blocked the other user
+func Blocked(userID rose.UserID, otherID rose.UserID) (bool, error) {
+	state, err := relationState(userID, otherID)
+	return state == storage.RelationBlocked, err
+}
+
+// Friends returns true if the two users are friends
+func Friends(userID rose.UserID, otherID rose.UserID) (bool, error) {
+	state, err := relationState(userID, otherID)
+	return state == storage.RelationFriends, err
+}
+
+// SendRequest ask another user to be friends
+func SendRequest(from rose.UserID, to rose.UserID) error {
+	if from == to {
+		return ErrSelf
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	theirs, err := relationState(to, from)
+	if err != nil {
+		return err
+	}
+	mine, err := relationState(from, to)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case theirs == storage.RelationBlocked || mine == storage.RelationBlocked:
+		return ErrBlocked
+	case mine == storage.RelationFriends:
+		return ErrAlreadyFriends
+	case theirs == storage.RelationPending:
+		// They asked first, so this is a yes
+		return accept(to, from)
+	case mine == storage.RelationPending:
+		// Asking again only refreshes the request
+	default:
+		if err := checkTarget(to); err != nil {
+			return err
+		}
+		if err := checkPending(from); err != nil {
+			return err
+		}
+	}
+
+	err = storage.Instance.SaveRelation(&storage.Relation{
+		UserID:  from,
+		OtherID: to,
+		State:   storage.RelationPending,
+		Created: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Let them know if they're around
+	if user, ok := lobby.GetUser(to); ok {
+		user.SendMessage(rose.MessageType(pb.MessageType_FriendRequestReceived), &pb.FriendActionRequest{UserId: uint64(from)})
+	}
+
+	return nil
+}
+
+// checkTarget make sure friend requests only go to users that exist, online or with a stored profile
+func checkTarget(userID rose.UserID) error {
+	if _, ok := lobby.GetUser(userID); ok {
+		return nil
+	}
+
+	_, err := storage.Instance.GetProfile(userID)
+	if err == storage.ErrNotFound {
+		return ErrUnknownUser
+	}
+	return err
+}
+
+// checkPending make sure the user stays below the configured number of unanswered requests
+func checkPending(userID rose.UserID) error {
+	relations, err := storage.Instance.GetRelations(userID)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, relation := range relations {
+		if relation.State == storage.RelationPending {
+			pending++
+		}
+	}
+	if pending >= config.Get().FriendPending {
+		return ErrTooManyRequests
+	}
+	return nil
+}
+
+// Accept accept the friend request sent by from
+func Accept(userID rose.UserID, from rose.UserID) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := relationState(from, userID)
+	if err != nil {
+		return err
+	}
+	if state != storage.RelationPending {
+		return ErrNoRequest
+	}
+
+	return accept(from, userID)
+}
+
+// accept turn the pending request from requester to accepter into a friendship, must be called with the lock held
+func accept(requester rose.UserID, accepter rose.UserID) error {
+	now := time.Now().UTC()
+	for _, relation := range []*storage.Relation{
+		{UserID: requester, OtherID: accepter, State: storage.RelationFriends, Created: now},
+		{UserID: accepter, OtherID: requester, State: storage.RelationFriends, Created: now},
+	} {
+		if err := storage.Instance.SaveRelation(relation); err != nil {
+			return err
+		}
+	}
+
+	// The new friends now get to see where the other is
+	sendPresence(requester, accepter)
+	sendPresence(accepter, requester)
+
+	return nil
+}
+
+// Decline decline the friend request sent by from
+func Decline(userID rose.UserID, from rose.UserID) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := relationState(from, userID)
+	if err != nil {
+		return err
+	}
+	if state != storage.RelationPending {
+		return ErrNoRequest
+	}
+
+	return storage.Instance.RemoveRelation(from, userID)
+}
+
+// Remove end a friendship, or withdraw a friend request or block
+func Remove(userID rose.UserID, otherID rose.UserID) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := relationState(userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.Instance.RemoveRelation(userID, otherID); err != nil {
+		return err
+	}
+
+	// Friendships go both ways
+	if state == storage.RelationFriends {
+		if err := storage.Instance.RemoveRelation(otherID, userID); err != nil {
+			return err
+		}
+		hidePresence(otherID, userID)
+	}
+	return nil
+}
+
+// Block block another user, ending any friendship or pending requests between the two
+func Block(userID rose.UserID, otherID rose.UserID) error {
+	if userID == otherID {
+		return ErrSelf
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Leave their own block in place
+	theirs, err := relationState(otherID, userID)
+	if err != nil {
+		return err
+	}
+	if theirs != storage.RelationBlocked {
+		if err := storage.Instance.RemoveRelation(otherID, userID); err != nil {
+			return err
+		}
+	}
+
+	err = storage.Instance.SaveRelation(&storage.Relation{
+		UserID:  userID,
+		OtherID: otherID,
+		State:   storage.RelationBlocked,
+		Created: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A blocked friend no longer gets to see where the user is
+	if theirs == storage.RelationFriends {
+		hidePresence(otherID, userID)
+	}
+	return nil
+}
+
+// FriendList the friends with their presence, incoming requests and blocked users of the given user
+func FriendList(userID rose.UserID) (*pb.FriendListResponse, error) {
+	relations, err := storage.Instance.GetRelations(userID)
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := storage.Instance.GetIncomingRequests(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.FriendListResponse{
+		Success:  true,
+		Friends:  make([]*pb.FriendPresence, 0),
+		Outgoing: make([]uint64, 0),
+		Incoming: make([]uint64, 0, len(incoming)),
+		Blocked:  make([]uint64, 0),
+	}
+
+	for _, relation := range relations {
+		switch relation.State {
+		case storage.RelationFriends:
+			response.Friends = append(response.Friends, PresenceOf(relation.OtherID))
+		case storage.RelationPending:
+			response.Outgoing = append(response.Outgoing, uint64(relation.OtherID))
+		case storage.RelationBlocked:
+			response.Blocked = append(response.Blocked, uint64(relation.OtherID))
+		}
+	}
+
+	for _, relation := range incoming {
+		response.Incoming = append(response.Incoming, uint64(relation.UserID))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,106 @@
+package social
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+var (
+	// ErrNotInRoom users can only invite others to the room they are in
+	ErrNotInRoom = errors.New("social: not in that room")
+	// ErrOffline the invited user is not connected
+	ErrOffline = errors.New("social: user is offline")
+	// ErrNoInvite there is no valid invite for that room
+	ErrNoInvite = errors.New("social: no invite")
+)
+
+type inviteKey struct {
+	to     rose.UserID
+	roomID rose.RoomID
+}
+
+type invite struct {
+	from    rose.UserID
+	expires time.Time
+}
+
+var (
+	invites     = make(map[inviteKey]invite)
+	invitesLock sync.Mutex
+)
+
+// Invite invite another online user to the room the inviter is in
+func Invite(from rose.UserID, to rose.UserID, roomID rose.RoomID, timeout time.Duration) error {
+	if from == to {
+		return ErrSelf
+	}
+
+	if current, ok := lobby.GetUserRoom(from); !ok || current != roomID {
+		return ErrNotInRoom
+	}
+
+	target, ok := lobby.GetUser(to)
+	if !ok {
+		return ErrOffline
+	}
+
+	// Blocked users don't get to bother anyone
+	state, err := relationState(to, from)
+	if err != nil {
+		return err
+	}
+	if state == storage.RelationBlocked {
+		return ErrBlocked
+	}
+
+	now := time.Now()
+	expires := now.Add(timeout)
+
+	invitesLock.Lock()
+	purgeInvites(now)
+	invites[inviteKey{to, roomID}] = invite{from: from, expires: expires}
+	invitesLock.Unlock()
+
+	target.SendMessage(rose.MessageType(pb.MessageType_InviteReceived), &pb.RoomInvite{
+		FromUserId: uint64(from),
+		RoomId:     uint64(roomID),
+		Expires:    expires.UnixNano() / 1e6,
+	})
+
+	return nil
+}
+
+// CheckInvite returns an error if the user has no invite for the given room that is still valid
+func CheckInvite(userID rose.UserID, roomID rose.RoomID) error {
+	invitesLock.Lock()
+	defer invitesLock.Unlock()
+
+	invite, ok := invites[inviteKey{userID, roomID}]
+	if !ok || time.Now().After(invite.expires) {
+		return ErrNoInvite
+	}
+	return nil
+}
+
+// ConsumeInvite use up the invite of the user for the given room, once they got to join it
+func ConsumeInvite(userID rose.UserID, roomID rose.RoomID) {
+	invitesLock.Lock()
+	defer invitesLock.Unlock()
+
+	delete(invites, inviteKey{userID, roomID})
+}
+
+// purgeInvites drop expired invites, must be called with the lock held
+func purgeInvites(now time.Time) {
+	for key, invite := range invites {
+		if now.After(invite.expires) {
+			delete(invites, key)
+		}
+	}
+}
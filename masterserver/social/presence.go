@@ -0,0 +1,109 @@
+package social
+
+import (
+	"sync"
+
+	"github.com/zeroZshadow/rose"
+	"github.com/zeroZshadow/rose-example/masterserver/lobby"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+	"github.com/zeroZshadow/rose-example/shared/storage"
+)
+
+// Users whose friends still need to hear about their presence, pushed by the presence worker
+var (
+	changed     = make(map[rose.UserID]struct{})
+	changedLock sync.Mutex
+	changedWake = make(chan struct{}, 1)
+	startWorker sync.Once
+)
+
+// PresenceOf where the given user currently is.
+// There is no matchmaking queue yet, so nobody is reported as Presence_InQueue.
+func PresenceOf(userID rose.UserID) *pb.FriendPresence {
+	presence := &pb.FriendPresence{
+		UserId:   uint64(userID),
+		Presence: pb.Presence_Offline,
+	}
+
+	// Playing counts, even if the connection to the master was dropped
+	if roomID, ok := lobby.GetUserRoom(userID); ok {
+		presence.Presence = pb.Presence_InRoom
+		presence.RoomId = uint64(roomID)
+		return presence
+	}
+
+	if _, ok := lobby.GetUser(userID); ok {
+		presence.Presence = pb.Presence_Online
+	}
+
+	return presence
+}
+
+// PresenceChanged queue pushing the presence of the given user to all of their online friends.
+// Looking up friends hits storage, so it's done in the background instead of on the caller's loop,
+// and several changes of the same user before the push only send the latest presence.
+func PresenceChanged(userID rose.UserID) {
+	startWorker.Do(func() {
+		go presenceWorker()
+	})
+
+	changedLock.Lock()
+	changed[userID] = struct{}{}
+	changedLock.Unlock()
+
+	select {
+	case changedWake <- struct{}{}:
+	default:
+	}
+}
+
+// presenceWorker push presence for every queued user, until the end of the program
+func presenceWorker() {
+	for range changedWake {
+		changedLock.Lock()
+		users := changed
+		changed = make(map[rose.UserID]struct{})
+		changedLock.Unlock()
+
+		for userID := range users {
+			pushPresence(userID)
+		}
+	}
+}
+
+// pushPresence send the presence of the given user to all of their online friends
+func pushPresence(userID rose.UserID) {
+	relations, err := storage.Instance.GetRelations(userID)
+	if err != nil {
+		log.Errorf("Failed to load friends of user %d: %s", userID, err)
+		return
+	}
+
+	presence := PresenceOf(userID)
+	for _, relation := range relations {
+		if relation.State != storage.RelationFriends {
+			continue
+		}
+
+		if friend, ok := lobby.GetUser(relation.OtherID); ok {
+			friend.SendMessage(rose.MessageType(pb.MessageType_PresenceUpdate), presence)
+		}
+	}
+}
+
+// sendPresence send the presence of one user to another, if they are online
+func sendPresence(to rose.UserID, about rose.UserID) {
+	if user, ok := lobby.GetUser(to); ok {
+		user.SendMessage(rose.MessageType(pb.MessageType_PresenceUpdate), PresenceOf(about))
+	}
+}
+
+// hidePresence tell a former friend the user went offline, so their client stops showing where the user is
+func hidePresence(to rose.UserID, about rose.UserID) {
+	if user, ok := lobby.GetUser(to); ok {
+		user.SendMessage(rose.MessageType(pb.MessageType_PresenceUpdate), &pb.FriendPresence{
+			UserId:   uint64(about),
+			Presence: pb.Presence_Offline,
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package pb the messages exchanged between clients, the master and the nodes
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative messages.proto
@@ -0,0 +1,230 @@
+// replaytool project main.go
+// Inspect and verify match replays recorded by the gameserver
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose-example/gameserver/config"
+	"github.com/zeroZshadow/rose-example/gameserver/replay"
+	"github.com/zeroZshadow/rose-example/gameserver/room"
+	"github.com/zeroZshadow/rose-example/messages/pb"
+)
+
+var (
+	configFile string
+	payloads   bool
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "", "Path to the gameserver config file, only used by replays that don't store their room settings")
+	flag.BoolVar(&payloads, "payloads", false, "Print message payloads when inspecting")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] inspect|verify <file.replay>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Older replays need the same room settings the gameserver used
+	cfg, err := config.Load(configFile, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while loading config: %s\n", err)
+		os.Exit(1)
+	}
+	cfg.ReplayDir = ""
+	config.Set(cfg)
+
+	// Only let the room speak up when something is wrong
+	logging.SetLevel(logging.WARNING, "")
+
+	switch flag.Arg(0) {
+	case "inspect":
+		err = inspect(flag.Arg(1))
+	case "verify":
+		err = verify(flag.Arg(1))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// inspect print the header and every record of the replay
+func inspect(path string) error {
+	reader, err := replay.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := reader.Header()
+	fmt.Printf("Room %d, recorded %s, format version %d\n", header.RoomID, header.Start.UTC().Format("2006-01-02 15:04:05"), header.Version)
+	if len(header.Settings) > 0 {
+		fmt.Printf("Settings %s\n", header.Settings)
+	}
+
+	count := 0
+	var last *replay.Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%8d %10s %-5s user %-20d %s (%d bytes)\n", record.Tick, record.Offset, record.Direction, record.UserID, messageName(record), len(record.Payload))
+		if payloads && len(record.Payload) > 0 {
+			fmt.Printf("%x\n", record.Payload)
+		}
+
+		count++
+		last = record
+	}
+
+	if last != nil {
+		fmt.Printf("%d records over %d ticks, %s\n", count, last.Tick, last.Offset)
+	}
+	return nil
+}
+
+// verify play the replay back and check the room sends exactly what it sent when it was recorded
+func verify(path string) error {
+	reader, err := replay.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Record the playback next to the original
+	temp, err := ioutil.TempFile("", "replay")
+	if err != nil {
+		return err
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	header := reader.Header()
+	out, err := replay.Create(temp.Name(), header.RoomID, header.Start, header.Settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = room.Playback(reader, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("playback failed: %s", err)
+	}
+
+	expected, err := outboundByTick(path)
+	if err != nil {
+		return err
+	}
+	actual, err := outboundByTick(temp.Name())
+	if err != nil {
+		return err
+	}
+
+	// Messages sent in the same tick may go out in any order
+	ticks := make([]uint64, 0, len(expected))
+	for tick := range expected {
+		ticks = append(ticks, tick)
+	}
+	for tick := range actual {
+		if _, ok := expected[tick]; !ok {
+			ticks = append(ticks, tick)
+		}
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i] < ticks[j] })
+
+	for _, tick := range ticks {
+		if !sameRecords(expected[tick], actual[tick]) {
+			return fmt.Errorf("replay diverged at tick %d: recorded %d messages, playback sent %d", tick, len(expected[tick]), len(actual[tick]))
+		}
+	}
+
+	fmt.Printf("Replay of room %d verified, %d ticks with output match\n", header.RoomID, len(ticks))
+	return nil
+}
+
+// outboundByTick read all outbound records of a replay, grouped by tick
+func outboundByTick(path string) (map[uint64][]*replay.Record, error) {
+	reader, err := replay.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	records := make(map[uint64][]*replay.Record)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if record.Direction == replay.Outbound {
+			records[record.Tick] = append(records[record.Tick], record)
+		}
+	}
+}
+
+// sameRecords compare two sets of records regardless of order
+func sameRecords(a, b []*replay.Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	less := func(records []*replay.Record) func(i, j int) bool {
+		return func(i, j int) bool {
+			x, y := records[i], records[j]
+			if x.UserID != y.UserID {
+				return x.UserID < y.UserID
+			}
+			if x.MessageType != y.MessageType {
+				return x.MessageType < y.MessageType
+			}
+			return bytes.Compare(x.Payload, y.Payload) < 0
+		}
+	}
+	sort.Slice(a, less(a))
+	sort.Slice(b, less(b))
+
+	for i := range a {
+		if a[i].UserID != b[i].UserID || a[i].MessageType != b[i].MessageType || !bytes.Equal(a[i].Payload, b[i].Payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func messageName(record *replay.Record) string {
+	if record.Direction == replay.Join || record.Direction == replay.Leave {
+		return "-"
+	}
+	return pb.MessageType(record.MessageType).String()
+}
@@ -0,0 +1,126 @@
+// Package adminapi holds what the master and game server admin HTTP APIs have in common:
+// the token protected listener and helpers to answer requests.
+package adminapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose-example/shared/audit"
+)
+
+var log = logging.MustGetLogger("adminapi")
+
+// Serve start serving on the given address. Public handlers are served as is, by exact path,
+// everything else goes to the handler and needs the bearer token. Without a token only the public
+// handlers are available. The listener runs until the process exits.
+func Serve(address string, token string, handler http.Handler, public map[string]http.Handler) (net.Addr, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	private := Authenticate(token, handler)
+	if token == "" {
+		private = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, http.StatusForbidden, "admin API disabled, no admin token configured")
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", private)
+	for path, publicHandler := range public {
+		mux.Handle(path, publicHandler)
+	}
+
+	go func() {
+		err := http.Serve(listener, mux)
+		log.Errorf("Admin API stopped: %s", err)
+	}()
+
+	return listener.Addr(), nil
+}
+
+// Authenticate only let requests with the bearer token through
+func Authenticate(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(given, expected) != 1 {
+			log.Warningf("Unauthorized admin request from %s for %s", r.RemoteAddr, r.URL.Path)
+			audit.RecordLimited("admin.unauthorized", audit.Admin(r.RemoteAddr), "", map[string]string{"method": r.Method, "path": r.URL.Path})
+			WriteError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SplitPath split /prefix/{id}/{action} into the id and the optional action
+func SplitPath(path string, prefix string) (uint64, string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	if len(parts) == 0 || len(parts) > 2 {
+		return 0, "", false
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	return id, action, true
+}
+
+// RequireMethod returns false and answers the request if it used the wrong method
+func RequireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// WriteJSON answer with the value as JSON
+func WriteJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Warningf("Failed to write admin response: %s", err)
+	}
+}
+
+// WriteError answer with an error message as JSON
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"error": message})
+}
+
+// HealthHandler liveness, answers as long as the process is able to serve HTTP
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// ReadyHandler readiness, not ready while the check returns an error
+func ReadyHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "reason": err.Error()})
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+}
@@ -0,0 +1,100 @@
+// Package audit keeps a trail of security relevant events, separate from the debug logs.
+// Events go to a Sink, the file sink chains every line to the one before it with a
+// sha256 hash, so lines that are changed, removed or reordered are detected by Verify.
+// Lines cut off the end can only be caught by comparing the last hash to one kept elsewhere.
+// Events clients can cause at will go through RecordLimited, so they can't flood the trail.
+package audit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("audit")
+
+// Event something security relevant that happened
+type Event struct {
+	Time    time.Time         `json:"time"`
+	Server  string            `json:"server"`
+	Action  string            `json:"action"`
+	Actor   string            `json:"actor,omitempty"`
+	Target  string            `json:"target,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Sink stores audit events
+type Sink interface {
+	Record(event Event) error
+	Close() error
+}
+
+// nopSink drops events, used until a sink is configured
+type nopSink struct{}
+
+func (nopSink) Record(event Event) error { return nil }
+func (nopSink) Close() error             { return nil }
+
+var (
+	sink     Sink = nopSink{}
+	server   string
+	sinkLock sync.Mutex
+)
+
+// SetSink start sending events to the sink, tagged with the server they came from.
+// The previous sink is closed.
+func SetSink(name string, newSink Sink) {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+
+	if err := sink.Close(); err != nil {
+		log.Errorf("Unable to close audit sink: %s", err)
+	}
+	server = name
+	sink = newSink
+}
+
+// Close the sink, later events are dropped
+func Close() {
+	SetSink("", nopSink{})
+}
+
+// Record an event, details may be nil. Failing to record is logged, but doesn't stop the action.
+func Record(action string, actor string, target string, details map[string]string) {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+
+	event := Event{
+		Time:    time.Now().UTC(),
+		Server:  server,
+		Action:  action,
+		Actor:   actor,
+		Target:  target,
+		Details: details,
+	}
+	if err := sink.Record(event); err != nil {
+		log.Errorf("Unable to record audit event %s: %s", action, err)
+	}
+}
+
+// User names a user as actor or target
+func User(id uint64) string {
+	return "user:" + strconv.FormatUint(id, 10)
+}
+
+// Node names a game node as actor or target
+func Node(id uint64) string {
+	return "node:" + strconv.FormatUint(id, 10)
+}
+
+// Room names a room as target
+func Room(id uint64) string {
+	return "room:" + strconv.FormatUint(id, 10)
+}
+
+// Admin names an admin API caller by address
+func Admin(remoteAddr string) string {
+	return "admin@" + remoteAddr
+}
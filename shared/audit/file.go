@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// genesis the previous hash of the first line in a file
+const genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// line how an event is stored, the hash covers the sequence number, previous hash and event as written
+type line struct {
+	Seq   uint64          `json:"seq"`
+	Prev  string          `json:"prev"`
+	Hash  string          `json:"hash"`
+	Event json.RawMessage `json:"event"`
+}
+
+// hashLine the hash of a line, given the exact bytes of its event
+func hashLine(seq uint64, prev string, event []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(strconv.FormatUint(seq, 10)))
+	sum.Write([]byte{'\n'})
+	sum.Write([]byte(prev))
+	sum.Write([]byte{'\n'})
+	sum.Write(event)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// FileSink appends events as JSON lines, each chained to the one before it.
+// Only one process may write to a file.
+type FileSink struct {
+	file *os.File
+	seq  uint64
+	prev string
+}
+
+// OpenFile open the audit file, continuing the chain of what's already in it.
+// A file that doesn't verify is refused, so a broken trail is never extended silently.
+func OpenFile(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &FileSink{file: file, prev: genesis}
+	last, err := verify(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit file %s does not verify: %s", path, err)
+	}
+	if last != nil {
+		sink.seq = last.Seq
+		sink.prev = last.Hash
+	}
+
+	return sink, nil
+}
+
+// Record implements Sink.Record, the line is synced to disk before returning
+func (sink *FileSink) Record(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	seq := sink.seq + 1
+	entry := line{
+		Seq:   seq,
+		Prev:  sink.prev,
+		Hash:  hashLine(seq, sink.prev, data),
+		Event: data,
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sink.file.Write(append(encoded, '\n')); err != nil {
+		return err
+	}
+	if err := sink.file.Sync(); err != nil {
+		return err
+	}
+
+	sink.seq = seq
+	sink.prev = entry.Hash
+	return nil
+}
+
+// Close implements Sink.Close
+func (sink *FileSink) Close() error {
+	return sink.file.Close()
+}
+
+// Verify check the hash chain of an audit file, returns the number of events in it and the hash of the last one
+func Verify(reader io.Reader) (uint64, string, error) {
+	last, err := verify(reader)
+	if err != nil || last == nil {
+		return 0, genesis, err
+	}
+	return last.Seq, last.Hash, nil
+}
+
+// verify check the chain, returns the last line or nil if there are none
+func verify(reader io.Reader) (*line, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var last *line
+	prev := genesis
+	for number := uint64(1); scanner.Scan(); number++ {
+		entry := &line{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("line %d: %s", number, err)
+		}
+
+		if entry.Seq != number {
+			return nil, fmt.Errorf("line %d: sequence number is %d", number, entry.Seq)
+		}
+		if entry.Prev != prev {
+			return nil, fmt.Errorf("line %d: previous hash doesn't match line %d", number, number-1)
+		}
+		if entry.Hash != hashLine(entry.Seq, entry.Prev, entry.Event) {
+			return nil, fmt.Errorf("line %d: hash doesn't match its contents", number)
+		}
+
+		prev = entry.Hash
+		last = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return last, nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func recordTestEvents(t *testing.T, path string, actions ...string) {
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range actions {
+		if err := sink.Record(Event{Time: time.Now().UTC(), Server: "test", Action: action, Actor: Node(1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChainContinues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	recordTestEvents(t, path, "node.register", "user.kick")
+	recordTestEvents(t, path, "user.ban")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, head, err := Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 events, got %d", count)
+	}
+	if head == genesis {
+		t.Error("expected the head to be the hash of the last event")
+	}
+}
+
+func TestTamperDetected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	recordTestEvents(t, path, "node.register", "user.kick", "user.ban")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+
+	tampered := map[string][]byte{
+		"changed":   bytes.Replace(data, []byte("user.kick"), []byte("user.kiss"), 1),
+		"removed":   bytes.Join([][]byte{lines[0], lines[2]}, nil),
+		"reordered": bytes.Join([][]byte{lines[1], lines[0], lines[2]}, nil),
+	}
+	for name, content := range tampered {
+		if _, _, err := Verify(bytes.NewReader(content)); err == nil {
+			t.Errorf("%s: expected the chain to break", name)
+		}
+
+		// A broken file is never extended
+		broken := filepath.Join(t.TempDir(), name+".log")
+		if err := ioutil.WriteFile(broken, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenFile(broken); err == nil {
+			t.Errorf("%s: expected OpenFile to refuse the file", name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Events anyone can trigger are limited per action and actor, a flood of them would otherwise
+// have every request wait on the disk. Past the burst they are only counted.
+// One noisy actor doesn't hide the events of others, unless they share an actor, like "" for anonymous ones.
+const (
+	limitWindow = time.Minute
+	limitBurst  = 10
+)
+
+// limit how often an action was recorded in the current window
+type limit struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// limitKey what events are limited by
+type limitKey struct {
+	action string
+	actor  string
+}
+
+var (
+	limits     = make(map[limitKey]*limit)
+	limitsLock sync.Mutex
+	lastSweep  time.Time
+)
+
+// RecordLimited record an event clients can trigger at will, like a rejected token.
+// Past limitBurst events of an action by the same actor per limitWindow the events are dropped,
+// how many is recorded as an "audit.suppressed" event once the window ends.
+func RecordLimited(action string, actor string, target string, details map[string]string) {
+	if allow(limitKey{action, actor}, time.Now()) {
+		Record(action, actor, target, details)
+	}
+}
+
+// allow returns true if the event may be recorded now, otherwise it is counted as suppressed
+func allow(key limitKey, now time.Time) bool {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	sweep(now)
+
+	current, ok := limits[key]
+	if !ok {
+		current = &limit{start: now}
+		limits[key] = current
+	}
+	if now.Sub(current.start) >= limitWindow {
+		current.start = now
+		current.count = 0
+	}
+
+	if current.count < limitBurst {
+		current.count++
+		return true
+	}
+
+	// The first suppressed event schedules the summary, later ones are added to it
+	if current.suppressed == 0 {
+		time.AfterFunc(current.start.Add(limitWindow).Sub(now), func() {
+			recordSuppressed(key)
+		})
+	}
+	current.suppressed++
+	return false
+}
+
+// sweep forget limits whose window is over and that have nothing left to summarize, at most once per window.
+// Every actor gets a limit, without this they would pile up. Must be called with the lock held.
+func sweep(now time.Time) {
+	if now.Sub(lastSweep) < limitWindow {
+		return
+	}
+	lastSweep = now
+
+	for key, current := range limits {
+		if now.Sub(current.start) >= limitWindow && current.suppressed == 0 {
+			delete(limits, key)
+		}
+	}
+}
+
+// recordSuppressed record how many events of the action by the actor were dropped since the last summary
+func recordSuppressed(key limitKey) {
+	limitsLock.Lock()
+	count := 0
+	if current, ok := limits[key]; ok {
+		count = current.suppressed
+		current.suppressed = 0
+	}
+	limitsLock.Unlock()
+
+	if count > 0 {
+		Record("audit.suppressed", key.actor, "", map[string]string{
+			"action": key.action,
+			"count":  strconv.Itoa(count),
+		})
+	}
+}
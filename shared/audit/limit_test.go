@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	action := limitKey{"test.allow", "user:1"}
+	start := time.Now()
+
+	for i := 0; i < limitBurst; i++ {
+		if !allow(action, start) {
+			t.Fatalf("expected event %d to be allowed", i+1)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if allow(action, start.Add(time.Second)) {
+			t.Fatal("expected events past the burst to be suppressed")
+		}
+	}
+
+	limitsLock.Lock()
+	suppressed := limits[action].suppressed
+	limitsLock.Unlock()
+	if suppressed != 3 {
+		t.Errorf("expected 3 suppressed events, got %d", suppressed)
+	}
+
+	// A new window allows a new burst
+	if !allow(action, start.Add(limitWindow)) {
+		t.Error("expected the next window to allow events again")
+	}
+
+	// Other actions and actors have their own limit
+	if !allow(limitKey{"test.other", "user:1"}, start.Add(time.Second)) {
+		t.Error("expected a different action to be allowed")
+	}
+	if !allow(limitKey{"test.allow", "user:2"}, start.Add(time.Second)) {
+		t.Error("expected a different actor to be allowed")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	quiet := limitKey{"test.sweep", "user:1"}
+	noisy := limitKey{"test.sweep", "user:2"}
+
+	allow(quiet, start)
+	for i := 0; i <= limitBurst; i++ {
+		allow(noisy, start)
+	}
+
+	// Limits that are over go, unless they still have to report suppressed events
+	allow(limitKey{"test.sweep", "user:3"}, start.Add(limitWindow))
+
+	limitsLock.Lock()
+	_, quietKept := limits[quiet]
+	_, noisyKept := limits[noisy]
+	limitsLock.Unlock()
+	if quietKept {
+		t.Error("expected the finished limit to be swept")
+	}
+	if !noisyKept {
+		t.Error("expected the limit with suppressed events to be kept")
+	}
+}
+
+type memorySink struct {
+	events []Event
+}
+
+func (sink *memorySink) Record(event Event) error {
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+func (sink *memorySink) Close() error { return nil }
+
+func TestRecordSuppressed(t *testing.T) {
+	sink := &memorySink{}
+	SetSink("test", sink)
+	defer Close()
+
+	action := limitKey{"test.summary", "user:1"}
+	start := time.Now()
+	for i := 0; i < limitBurst+5; i++ {
+		allow(action, start)
+	}
+
+	recordSuppressed(action)
+	if len(sink.events) != 1 {
+		t.Fatalf("expected a single summary event, got %d", len(sink.events))
+	}
+	event := sink.events[0]
+	if event.Action != "audit.suppressed" || event.Actor != "user:1" || event.Details["action"] != "test.summary" || event.Details["count"] != "5" {
+		t.Errorf("unexpected summary %+v", event)
+	}
+
+	// Nothing new was dropped, so there is nothing to record
+	recordSuppressed(action)
+	if len(sink.events) != 1 {
+		t.Errorf("expected no second summary, got %d events", len(sink.events))
+	}
+}
@@ -0,0 +1,77 @@
+package configload
+
+import (
+	"github.com/op/go-logging"
+)
+
+// Common settings every server has: logging, tracing and the audit trail.
+// Embed it in a config without a json tag, its fields then sit next to the server's own.
+type Common struct {
+	LogLevel         string            `json:"loglevel" reload:"true"`
+	LogLevels        map[string]string `json:"loglevels" reload:"true"` // Level per module, overrides LogLevel
+	LogFormat        string            `json:"logformat"`               // "text" or "json"
+	LogFileLevel     string            `json:"logfilelevel"`            // Only this level or worse goes to the log file
+	LogMaxSize       int               `json:"logmaxsize"`              // Megabytes before the log file is rotated
+	LogMaxBackups    int               `json:"logmaxbackups"`           // Rotated log files to keep, 0 keeps all
+	LogMaxAge        int               `json:"logmaxage"`               // Days to keep rotated log files, 0 keeps them forever
+	LogSampleEvery   int               `json:"logsampleevery"`          // Past 100 debug lines per module per second only log one in this many, 0 disables
+	TraceExporter    string            `json:"traceexporter"`           // "" disables tracing, "stdout" or "file"
+	TraceFile        string            `json:"tracefile"`               // Where the file exporter writes spans
+	TraceSampleRatio float64           `json:"tracesampleratio"`        // Share of room requests to trace, 0 to 1
+	AuditFile        string            `json:"auditfile"`               // Empty disables the audit trail, one process per file
+}
+
+// DefaultCommon the common settings with default values
+func DefaultCommon() Common {
+	return Common{
+		LogLevel:         "DEBUG",
+		LogLevels:        map[string]string{},
+		LogFormat:        "text",
+		LogFileLevel:     "ERROR",
+		LogMaxSize:       100,
+		LogMaxBackups:    5,
+		LogMaxAge:        28,
+		LogSampleEvery:   0,
+		TraceExporter:    "",
+		TraceFile:        "traces.json",
+		TraceSampleRatio: 1,
+		AuditFile:        "",
+	}
+}
+
+// Validate add any problems with the common settings to errs
+func (common *Common) Validate(errs *Errors) {
+	if _, err := logging.LogLevel(common.LogLevel); err != nil {
+		errs.Add("loglevel must be CRITICAL, ERROR, WARNING, NOTICE, INFO or DEBUG, not %q", common.LogLevel)
+	}
+	for module, level := range common.LogLevels {
+		if _, err := logging.LogLevel(level); err != nil {
+			errs.Add("loglevels[%s] must be CRITICAL, ERROR, WARNING, NOTICE, INFO or DEBUG, not %q", module, level)
+		}
+	}
+	if common.LogFormat != "text" && common.LogFormat != "json" {
+		errs.Add("logformat must be \"text\" or \"json\", not %q", common.LogFormat)
+	}
+	if _, err := logging.LogLevel(common.LogFileLevel); err != nil {
+		errs.Add("logfilelevel must be CRITICAL, ERROR, WARNING, NOTICE, INFO or DEBUG, not %q", common.LogFileLevel)
+	}
+	if common.LogMaxSize < 0 || common.LogMaxBackups < 0 || common.LogMaxAge < 0 {
+		errs.Add("logmaxsize, logmaxbackups and logmaxage can't be negative")
+	}
+	if common.LogSampleEvery < 0 {
+		errs.Add("logsampleevery can't be negative")
+	}
+
+	switch common.TraceExporter {
+	case "", "stdout":
+	case "file":
+		if common.TraceFile == "" {
+			errs.Add("tracefile is required for the file trace exporter")
+		}
+	default:
+		errs.Add("traceexporter must be empty, \"stdout\" or \"file\", not %q", common.TraceExporter)
+	}
+	if common.TraceSampleRatio < 0 || common.TraceSampleRatio > 1 {
+		errs.Add("tracesampleratio must be between 0 and 1")
+	}
+}
@@ -0,0 +1,333 @@
+// Package configload fills a config struct in layers: the defaults already in the struct,
+// then a JSON, YAML or TOML file, then environment variables and finally command-line flags.
+//
+// Fields are named by their json tag everywhere. With a prefix of "MASTER" the field tagged
+// `json:"address"` is read from MASTER_ADDRESS and the -address flag. Lists can be given
+// comma separated, anything that isn't a plain value or list of plain values is given as JSON.
+// Fields tagged `secret:"true"` are redacted when the config is printed, fields tagged
+// `reload:"true"` are safe to change while running and are taken over by Merge.
+package configload
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted what secrets are replaced with when printing
+const redacted = "<redacted>"
+
+// field a config field that can be set by name
+type field struct {
+	name   string
+	index  []int
+	secret bool
+	reload bool
+}
+
+// fields the settable fields of the config struct, by json name
+func fields(cfg interface{}) []field {
+	return structFields(reflect.TypeOf(cfg).Elem(), nil)
+}
+
+// structFields the settable fields of the struct type, embedded structs without a json name are flattened like encoding/json does
+func structFields(typ reflect.Type, parent []int) []field {
+	result := make([]field, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		index := append(append([]int(nil), parent...), i)
+		name := strings.Split(structField.Tag.Get("json"), ",")[0]
+
+		if structField.Anonymous && name == "" && structField.Type.Kind() == reflect.Struct {
+			result = append(result, structFields(structField.Type, index)...)
+			continue
+		}
+		if name == "" || name == "-" || structField.PkgPath != "" {
+			continue
+		}
+
+		result = append(result, field{
+			name:   name,
+			index:  index,
+			secret: structField.Tag.Get("secret") == "true",
+			reload: structField.Tag.Get("reload") == "true",
+		})
+	}
+	return result
+}
+
+// flagValue collects a flag without touching the config, so it can be applied after the file and environment
+type flagValue struct {
+	value string
+}
+
+func (value *flagValue) String() string {
+	return value.value
+}
+
+func (value *flagValue) Set(raw string) error {
+	value.value = raw
+	return nil
+}
+
+// RegisterFlags add a flag for every config field, call before parsing the flags
+func RegisterFlags(flags *flag.FlagSet, cfg interface{}) {
+	defaults := reflect.ValueOf(cfg).Elem()
+
+	for _, f := range fields(cfg) {
+		usage := fmt.Sprintf("Overrides %s from the config file (default %s)", f.name, describe(defaults.FieldByIndex(f.index), f.secret))
+		flags.Var(&flagValue{}, f.name, usage)
+	}
+}
+
+// describe a default value for flag usage
+func describe(value reflect.Value, secret bool) string {
+	if secret && !value.IsZero() {
+		return redacted
+	}
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "?"
+	}
+	return string(data)
+}
+
+// Validator a config that can check itself once loaded
+type Validator interface {
+	Validate() error
+}
+
+// Load apply the file, environment variables with the prefix and flags that were set on top of the config.
+// Configs implementing Validator are validated afterwards.
+// Any error aborts loading, the config should not be used afterwards.
+func Load(cfg interface{}, file string, prefix string, flags *flag.FlagSet) error {
+	if file != "" {
+		if err := FromFile(cfg, file); err != nil {
+			return err
+		}
+	}
+
+	value := reflect.ValueOf(cfg).Elem()
+	known := fields(cfg)
+
+	// Environment variables
+	for _, f := range known {
+		name := strings.ToUpper(prefix + "_" + f.name)
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := set(value.FieldByIndex(f.index), raw); err != nil {
+				return fmt.Errorf("environment variable %s: %s", name, err)
+			}
+		}
+	}
+
+	// Flags, only the ones given on the command line
+	if flags != nil {
+		if err := fromFlags(value, known, flags); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := cfg.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// fromFlags set the fields of the flags that were given on the command line
+func fromFlags(value reflect.Value, known []field, flags *flag.FlagSet) error {
+	byName := make(map[string]field, len(known))
+	for _, f := range known {
+		byName[f.name] = f
+	}
+
+	var err error
+	flags.Visit(func(flagged *flag.Flag) {
+		f, ok := byName[flagged.Name]
+		if !ok || err != nil {
+			return
+		}
+		if setErr := set(value.FieldByIndex(f.index), flagged.Value.String()); setErr != nil {
+			err = fmt.Errorf("flag -%s: %s", flagged.Name, setErr)
+		}
+	})
+	return err
+}
+
+// FromFile read the file into the config, the format is picked by extension.
+// Fields the config doesn't know about are an error.
+func FromFile(cfg interface{}, file string) error {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Everything goes through JSON, so the json tags are all the config needs
+	data := content
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	case ".toml":
+		var values map[string]interface{}
+		if _, err := toml.Decode(string(content), &values); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	default:
+		return fmt.Errorf("%s: unknown config format, use .json, .yaml, .yml or .toml", file)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	return nil
+}
+
+// set parse the raw value into the field
+func set(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+		return nil
+
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+		return nil
+
+	case reflect.Slice:
+		// Lists of plain values can be given comma separated
+		if !strings.HasPrefix(strings.TrimSpace(raw), "[") && isPlain(value.Type().Elem().Kind()) {
+			items := []string{}
+			if raw != "" {
+				items = strings.Split(raw, ",")
+			}
+
+			list := reflect.MakeSlice(value.Type(), len(items), len(items))
+			for i, item := range items {
+				if err := set(list.Index(i), strings.TrimSpace(item)); err != nil {
+					return err
+				}
+			}
+			value.Set(list)
+			return nil
+		}
+	}
+
+	// Anything else is JSON
+	target := reflect.New(value.Type())
+	if err := json.Unmarshal([]byte(raw), target.Interface()); err != nil {
+		return err
+	}
+	value.Set(target.Elem())
+	return nil
+}
+
+func isPlain(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Print the config as indented JSON, with secrets redacted
+func Print(cfg interface{}) ([]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	value := reflect.ValueOf(cfg).Elem()
+	for _, f := range fields(cfg) {
+		if f.secret && !value.FieldByIndex(f.index).IsZero() {
+			values[f.name] = redacted
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(values); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), nil
+}
+
+// Errors collects validation problems, so they can all be reported at once
+type Errors []string
+
+// Add a problem
+func (errs *Errors) Add(format string, args ...interface{}) {
+	*errs = append(*errs, fmt.Sprintf(format, args...))
+}
+
+// Err returns the problems as an error, or nil if there are none
+func (errs Errors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config:\n  %s", strings.Join(errs, "\n  "))
+}
@@ -0,0 +1,167 @@
+package configload
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Regions []string `json:"regions"`
+	Token   string   `json:"token" secret:"true"`
+	Rate    float64  `json:"rate" reload:"true"`
+	ignored string
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := writeFile(t, "config.json", `{"address": "file", "port": 1, "regions": ["EU"]}`)
+
+	os.Setenv("TEST_PORT", "2")
+	os.Setenv("TEST_REGIONS", "US, EU")
+	defer os.Unsetenv("TEST_PORT")
+	defer os.Unsetenv("TEST_REGIONS")
+
+	cfg := &testConfig{Address: "default", Rate: 1}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(flags, cfg)
+	if err := flags.Parse([]string{"-port", "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Load(cfg, file, "test", flags); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defaults, then the file, then the environment, then flags
+	want := &testConfig{Address: "file", Port: 3, Regions: []string{"US", "EU"}, Rate: 1}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	unknown := writeFile(t, "config.json", `{"adress": "typo"}`)
+	if err := Load(&testConfig{}, unknown, "test", nil); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+
+	format := writeFile(t, "config.ini", `address=nope`)
+	if err := Load(&testConfig{}, format, "test", nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+
+	os.Setenv("TEST_PORT", "many")
+	defer os.Unsetenv("TEST_PORT")
+	if err := Load(&testConfig{}, "", "test", nil); err == nil || !strings.Contains(err.Error(), "TEST_PORT") {
+		t.Errorf("expected an error naming the environment variable, got %v", err)
+	}
+}
+
+func TestFromFileFormats(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "address: here\nport: 4\nregions: [EU]\n",
+		"config.toml": "address = \"here\"\nport = 4\nregions = [\"EU\"]\n",
+	} {
+		cfg := &testConfig{}
+		if err := FromFile(cfg, writeFile(t, name, content)); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if cfg.Address != "here" || cfg.Port != 4 || len(cfg.Regions) != 1 {
+			t.Errorf("%s: unexpected config %+v", name, cfg)
+		}
+	}
+}
+
+func TestPrintRedacts(t *testing.T) {
+	data, err := Print(&testConfig{Token: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), redacted) {
+		t.Errorf("expected the token to be redacted, got %s", data)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	current := &testConfig{Address: "old", Token: "old", Rate: 1}
+	next := &testConfig{Address: "new", Token: "new", Rate: 2}
+
+	changes := Diff(current, next)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Field == "token" && (change.Old != redacted || change.New != redacted) {
+			t.Errorf("expected the token change to be redacted, got %+v", change)
+		}
+	}
+
+	// Only reloadable fields are taken over
+	merged := Merge(current, next).(*testConfig)
+	if merged.Address != "old" || merged.Token != "old" || merged.Rate != 2 {
+		t.Errorf("unexpected merged config %+v", merged)
+	}
+	if current.Rate != 1 {
+		t.Error("expected Merge to leave the current config alone")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	if errs.Err() != nil {
+		t.Error("expected no error without problems")
+	}
+
+	errs.Add("port %d is too low", 0)
+	errs.Add("address is empty")
+	err := errs.Err()
+	if err == nil || !strings.Contains(err.Error(), "port 0 is too low") || !strings.Contains(err.Error(), "address is empty") {
+		t.Errorf("expected both problems in the error, got %v", err)
+	}
+}
+
+type embeddingConfig struct {
+	Address string `json:"address"`
+	Common
+}
+
+func (cfg *embeddingConfig) Validate() error {
+	var errs Errors
+	cfg.Common.Validate(&errs)
+	return errs.Err()
+}
+
+func TestLoadCommon(t *testing.T) {
+	file := writeFile(t, "config.json", `{"address": "here", "logformat": "json"}`)
+
+	os.Setenv("TEST_LOGLEVEL", "INFO")
+	defer os.Unsetenv("TEST_LOGLEVEL")
+
+	cfg := &embeddingConfig{Common: DefaultCommon()}
+	if err := Load(cfg, file, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Address != "here" || cfg.LogFormat != "json" || cfg.LogLevel != "INFO" {
+		t.Errorf("expected the embedded fields to load like the others, got %+v", cfg)
+	}
+
+	// Loading validates
+	os.Setenv("TEST_LOGLEVEL", "LOUD")
+	if err := Load(&embeddingConfig{Common: DefaultCommon()}, file, "test", nil); err == nil || !strings.Contains(err.Error(), "loglevel") {
+		t.Errorf("expected the log level to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package configload
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("configload")
+
+// settleTime how long the file has to be quiet before reloading, editors tend to write in several steps
+const settleTime = 250 * time.Millisecond
+
+// Change a field that differs between two configs
+type Change struct {
+	Field      string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+// Diff list the fields that differ between the configs, secrets only show up as changed
+func Diff(old interface{}, next interface{}) []Change {
+	oldValue := reflect.ValueOf(old).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+
+	var changes []Change
+	for _, f := range fields(old) {
+		a := oldValue.FieldByIndex(f.index)
+		b := nextValue.FieldByIndex(f.index)
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			continue
+		}
+
+		change := Change{Field: f.name, Old: redacted, New: redacted, Reloadable: f.reload}
+		if !f.secret {
+			change.Old = describe(a, false)
+			change.New = describe(b, false)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Merge returns a copy of current with the reloadable fields taken from next.
+// Everything else keeps its current value until the process restarts.
+func Merge(current interface{}, next interface{}) interface{} {
+	currentValue := reflect.ValueOf(current).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+
+	merged := reflect.New(currentValue.Type())
+	merged.Elem().Set(currentValue)
+	for _, f := range fields(current) {
+		if f.reload {
+			merged.Elem().FieldByIndex(f.index).Set(nextValue.FieldByIndex(f.index))
+		}
+	}
+	return merged.Interface()
+}
+
+// Apply log what changed between the configs and return the merged config to use.
+// Changes to fields that aren't reloadable are reported, but ignored until a restart.
+func Apply(current interface{}, next interface{}) interface{} {
+	changes := Diff(current, next)
+	if len(changes) == 0 {
+		log.Notice("Config reloaded, nothing changed")
+		return current
+	}
+
+	for _, change := range changes {
+		if change.Reloadable {
+			log.Noticef("Config %s changed from %s to %s", change.Field, change.Old, change.New)
+		} else {
+			log.Warningf("Config %s changed from %s to %s, this needs a restart to apply", change.Field, change.Old, change.New)
+		}
+	}
+	return Merge(current, next)
+}
+
+// Watch call reload whenever the file changes or the process receives SIGHUP.
+// Without a file only SIGHUP triggers a reload. Reloads are never run concurrently.
+func Watch(file string, reload func()) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	if file != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			signal.Stop(hangup)
+			return err
+		}
+
+		// Watch the directory, editors and deploy tools often replace the file instead of writing to it
+		file = filepath.Clean(file)
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			signal.Stop(hangup)
+			return err
+		}
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	go func() {
+		settle := time.NewTimer(settleTime)
+		settle.Stop()
+
+		for {
+			select {
+			case <-hangup:
+				log.Notice("Received SIGHUP, reloading config")
+				reload()
+
+			case event := <-events:
+				if filepath.Clean(event.Name) != file || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				settle.Reset(settleTime)
+
+			case <-settle.C:
+				log.Noticef("Config file %s changed, reloading config", file)
+				reload()
+
+			case err := <-watchErrors:
+				log.Warningf("Error while watching config file: %s", err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Current holds the config in use, swapped as a whole on reload
+type Current struct {
+	value atomic.Value
+}
+
+// Get the config in use, nil until one is set
+func (current *Current) Get() interface{} {
+	return current.value.Load()
+}
+
+// Set the config in use
+func (current *Current) Set(cfg interface{}) {
+	current.value.Store(cfg)
+}
+
+// Reload load next the same way as Load and swap in the settings that are safe to change while running.
+// An invalid config is rejected and the current one stays in use.
+func (current *Current) Reload(next interface{}, file string, prefix string, flags *flag.FlagSet) error {
+	if err := Load(next, file, prefix, flags); err != nil {
+		return err
+	}
+
+	current.Set(Apply(current.Get(), next))
+	return nil
+}
@@ -1,56 +1,330 @@
 package shared
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/op/go-logging"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var fancyFormat = logging.MustStringFormatter(
-	`%{color}%{time:15:04:05.000} %{shortfunc} > %{level:.4s} %{color:reset} %{message}`,
+	`%{color}%{time:15:04:05.000} %{module}.%{shortfunc} > %{level:.4s} %{color:reset} %{message}`,
 )
 
 var fileFormat = logging.MustStringFormatter(
-	`%{time:15:04:05} %{shortfunc} > %{level:.4s} %{message}`,
+	`%{time:15:04:05} %{module}.%{shortfunc} > %{level:.4s} %{message}`,
 )
 
-var log = logging.MustGetLogger("global")
-var errfile *os.File
+var log = logging.MustGetLogger("shared")
+
+var (
+	logFile *lumberjack.Logger
+	// loggedModules every module that got its own level, so it can be reset when it no longer has one
+	loggedModules = make(map[string]bool)
+	levelLock     sync.Mutex
+)
+
+// sampleBurst debug lines a module may log each second before sampling kicks in
+const sampleBurst = 100
+
+// LogOptions how to log, the zero value logs text to stderr only
+type LogOptions struct {
+	File        string // Empty disables logging to file
+	Format      string // "text" or "json"
+	FileLevel   string // Only this level or worse goes to the file, defaults to ERROR
+	MaxSize     int    // Megabytes before the file is rotated
+	MaxBackups  int    // Rotated files to keep, 0 keeps all
+	MaxAge      int    // Days to keep rotated files, 0 keeps them forever
+	SampleEvery int    // Once past the burst, only log one in this many debug lines, 0 disables sampling
+}
+
+// InitLogger initialize the logger, calling it again replaces the previous setup
+func InitLogger(options LogOptions) error {
+	stderrFormat, fileFormatter := fancyFormat, fileFormat
+	switch options.Format {
+	case "", "text":
+	case "json":
+		stderrFormat, fileFormatter = JSONFormatter{}, JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format %q", options.Format)
+	}
+
+	fileLevel := logging.ERROR
+	if options.FileLevel != "" {
+		level, err := logging.LogLevel(options.FileLevel)
+		if err != nil {
+			return err
+		}
+		fileLevel = level
+	}
 
-// InitLogger initialize the logger
-func InitLogger(filename string) {
 	// StdErr backend
 	backendStderr := logging.NewLogBackend(os.Stderr, "", 0)
-	backendStderrFormatter := logging.NewBackendFormatter(backendStderr, fancyFormat)
-	logging.SetBackend(backendStderrFormatter)
+	var backend logging.Backend = logging.NewBackendFormatter(backendStderr, stderrFormat)
 
-	if filename != "" {
-		// Open file to log to
-		file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		if err != nil {
-			log.Warning("Unable to log to file", err)
-			return
+	CloseLogger()
+	if options.File != "" {
+		// Rotate the file instead of letting it grow forever
+		logFile = &lumberjack.Logger{
+			Filename:   options.File,
+			MaxSize:    options.MaxSize,
+			MaxBackups: options.MaxBackups,
+			MaxAge:     options.MaxAge,
 		}
-		errfile = file
 
 		// Add a backend that writes to file
-		backendFile := logging.NewLogBackend(errfile, "", 0)
-		backendFileFormatter := logging.NewBackendFormatter(backendFile, fileFormat)
+		backendFile := logging.NewLogBackend(logFile, "", 0)
+		backendFileFormatter := logging.NewBackendFormatter(backendFile, fileFormatter)
 
-		// Only write errors or worse
+		// Only write the configured level or worse
 		backendFileLeveled := logging.AddModuleLevel(backendFileFormatter)
-		backendFileLeveled.SetLevel(logging.ERROR, "")
+		backendFileLeveled.SetLevel(fileLevel, "")
+
+		backend = logging.MultiLogger(backendFileLeveled, backend)
+	}
 
-		// Set backends
-		logging.SetBackend(backendFileLeveled, backendStderrFormatter)
+	if options.SampleEvery > 1 {
+		backend = &samplingBackend{
+			backend: backend,
+			every:   options.SampleEvery,
+			counts:  make(map[string]int),
+		}
 	}
 
+	// Set backends
+	logging.SetBackend(backend)
+
+	// A new backend starts without module levels
+	levelLock.Lock()
+	loggedModules = make(map[string]bool)
+	levelLock.Unlock()
+
 	log.Info("Logging initialized")
+	return nil
 }
 
 // CloseLogger Close open logger files
 func CloseLogger() {
-	if errfile != nil {
-		errfile.Close()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
+
+// SetLogLevels change the level for all loggers by name, such as "INFO" or "DEBUG".
+// Modules can be given their own level, modules left out use the default again.
+func SetLogLevels(name string, modules map[string]string) error {
+	level, err := logging.LogLevel(name)
+	if err != nil {
+		return err
+	}
+
+	moduleLevels := make(map[string]logging.Level, len(modules))
+	for module, moduleName := range modules {
+		moduleLevel, err := logging.LogLevel(moduleName)
+		if err != nil {
+			return fmt.Errorf("module %s: %s", module, err)
+		}
+		moduleLevels[module] = moduleLevel
+	}
+
+	levelLock.Lock()
+	defer levelLock.Unlock()
+
+	logging.SetLevel(level, "")
+	for module := range loggedModules {
+		if _, ok := moduleLevels[module]; !ok {
+			logging.SetLevel(level, module)
+		}
+	}
+	for module, moduleLevel := range moduleLevels {
+		logging.SetLevel(moduleLevel, module)
+		loggedModules[module] = true
+	}
+	return nil
+}
+
+// Fields extra values attached to a log line, such as the room or user it is about
+type Fields map[string]interface{}
+
+// Entry what a Logger logs, text formats show the fields after the message
+type Entry struct {
+	Message string
+	Fields  Fields
+}
+
+// String the message followed by the fields as key=value, sorted by key
+func (entry *Entry) String() string {
+	if len(entry.Fields) == 0 {
+		return entry.Message
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	builder.WriteString(entry.Message)
+	for _, key := range keys {
+		fmt.Fprintf(&builder, " %s=%v", key, entry.Fields[key])
+	}
+	return builder.String()
+}
+
+// Logger logs for a module with fields attached to every line
+type Logger struct {
+	logger *logging.Logger
+	fields Fields
+}
+
+// NewLogger create a logger for the module, fields may be nil
+func NewLogger(module string, fields Fields) *Logger {
+	logger := logging.MustGetLogger(module)
+	// Skip the level method and log, so the caller shows up instead
+	logger.ExtraCalldepth = 2
+	return &Logger{logger: logger, fields: fields}
+}
+
+// With returns a logger that adds the fields to the ones already attached
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Logger{logger: l.logger, fields: merged}
+}
+
+// Debugf logs a message using DEBUG as log level
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(logging.DEBUG, format, args)
+}
+
+// Infof logs a message using INFO as log level
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(logging.INFO, format, args)
+}
+
+// Noticef logs a message using NOTICE as log level
+func (l *Logger) Noticef(format string, args ...interface{}) {
+	l.log(logging.NOTICE, format, args)
+}
+
+// Warningf logs a message using WARNING as log level
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.log(logging.WARNING, format, args)
+}
+
+// Errorf logs a message using ERROR as log level
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(logging.ERROR, format, args)
+}
+
+// Criticalf logs a message using CRITICAL as log level
+func (l *Logger) Criticalf(format string, args ...interface{}) {
+	l.log(logging.CRITICAL, format, args)
+}
+
+func (l *Logger) log(level logging.Level, format string, args []interface{}) {
+	// Don't bother formatting what won't be logged
+	if !l.logger.IsEnabledFor(level) {
+		return
 	}
+
+	entry := &Entry{Message: fmt.Sprintf(format, args...), Fields: l.fields}
+	switch level {
+	case logging.DEBUG:
+		l.logger.Debug(entry)
+	case logging.INFO:
+		l.logger.Info(entry)
+	case logging.NOTICE:
+		l.logger.Notice(entry)
+	case logging.WARNING:
+		l.logger.Warning(entry)
+	case logging.ERROR:
+		l.logger.Error(entry)
+	default:
+		l.logger.Critical(entry)
+	}
+}
+
+// JSONFormatter formats records as one JSON object per line, fields of an Entry become keys
+type JSONFormatter struct{}
+
+// Format implements logging.Formatter
+func (JSONFormatter) Format(calldepth int, record *logging.Record, output io.Writer) error {
+	line := make(map[string]interface{})
+
+	if len(record.Args) == 1 {
+		if entry, ok := record.Args[0].(*Entry); ok {
+			for key, value := range entry.Fields {
+				line[key] = value
+			}
+			line["msg"] = entry.Message
+		}
+	}
+	if _, ok := line["msg"]; !ok {
+		line["msg"] = record.Message()
+	}
+
+	line["time"] = record.Time.Format(time.RFC3339Nano)
+	line["level"] = record.Level.String()
+	line["module"] = record.Module
+	if _, file, number, ok := runtime.Caller(calldepth + 1); ok {
+		line["caller"] = fmt.Sprintf("%s:%d", filepath.Base(file), number)
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(data)
+	return err
+}
+
+// samplingBackend lets through every line, except debug lines of modules that log too much
+type samplingBackend struct {
+	backend logging.Backend
+	every   int
+
+	lock   sync.Mutex
+	second int64
+	counts map[string]int
+}
+
+// Log implements logging.Backend
+func (b *samplingBackend) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	if level == logging.DEBUG && !b.keep(record.Module, record.Time) {
+		return nil
+	}
+	return b.backend.Log(level, calldepth+1, record)
+}
+
+// keep returns true if the debug line should be logged
+func (b *samplingBackend) keep(module string, now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Counts start over every second
+	if second := now.Unix(); second != b.second {
+		b.second = second
+		b.counts = make(map[string]int)
+	}
+
+	b.counts[module]++
+	count := b.counts[module]
+	return count <= sampleBurst || (count-sampleBurst)%b.every == 0
 }
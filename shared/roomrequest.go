@@ -7,4 +7,23 @@ type RoomRequest struct {
 	UserID    rose.UserID
 	RoomID    rose.RoomID
 	Timestamp int64
+	Profile   PlayerProfile
+	Spectator bool
+
+	// RoomType and TickRate the room asked for on creation, the node checks them against its config
+	RoomType string
+	TickRate int
+
+	// InviteOnly the room was created for invited users only
+	InviteOnly bool
+
+	// TraceParent continues the master's trace on the node, empty if not traced
+	TraceParent string
+}
+
+// PlayerProfile public profile of the player, passed along to the room
+type PlayerProfile struct {
+	DisplayName string
+	AvatarID    int
+	Level       int
 }
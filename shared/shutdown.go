@@ -0,0 +1,30 @@
+package shared
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zeroZshadow/rose"
+)
+
+// WaitForShutdown block until the server stops by itself, or the process is asked to stop.
+// Returns the signal that asked us to stop, or nil if the server stopped.
+func WaitForShutdown(server *rose.Server) os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case sig := <-signals:
+		return sig
+	case <-stopped:
+		return nil
+	}
+}
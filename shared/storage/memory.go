@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/zeroZshadow/rose"
+)
+
+// Memory a Repository that keeps everything in memory, meant for tests and development
+type Memory struct {
+	accounts  map[rose.UserID]Account
+	profiles  map[rose.UserID]Profile
+	matches   map[rose.RoomID]*Match
+	bans      map[rose.UserID]Ban
+	scores    map[leaderboardKey]LeaderboardScore
+	ratings   map[ratingKey]Rating
+	changes   []RatingChange
+	relations map[relationKey]Relation
+
+	sync.RWMutex
+}
+
+type relationKey struct {
+	userID  rose.UserID
+	otherID rose.UserID
+}
+
+type ratingKey struct {
+	userID rose.UserID
+	mode   string
+}
+
+type leaderboardKey struct {
+	board  string
+	season string
+	userID rose.UserID
+}
+
+// NewMemory create an empty in-memory repository
+func NewMemory() *Memory {
+	return &Memory{
+		accounts:  make(map[rose.UserID]Account),
+		profiles:  make(map[rose.UserID]Profile),
+		matches:   make(map[rose.RoomID]*Match),
+		bans:      make(map[rose.UserID]Ban),
+		scores:    make(map[leaderboardKey]LeaderboardScore),
+		ratings:   make(map[ratingKey]Rating),
+		relations: make(map[relationKey]Relation),
+	}
+}
+
+// GetAccount implements Repository.GetAccount
+func (memory *Memory) GetAccount(id rose.UserID) (*Account, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	account, ok := memory.accounts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &account, nil
+}
+
+// SaveAccount implements Repository.SaveAccount
+func (memory *Memory) SaveAccount(account *Account) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	memory.accounts[account.ID] = *account
+	return nil
+}
+
+// GetProfile implements Repository.GetProfile
+func (memory *Memory) GetProfile(userID rose.UserID) (*Profile, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	profile, ok := memory.profiles[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	profile.Attributes = append(json.RawMessage(nil), profile.Attributes...)
+	return &profile, nil
+}
+
+// SaveProfile implements Repository.SaveProfile
+func (memory *Memory) SaveProfile(profile *Profile) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	stored := *profile
+	stored.Attributes = append(json.RawMessage(nil), profile.Attributes...)
+	memory.profiles[profile.UserID] = stored
+	return nil
+}
+
+// GetMatch implements Repository.GetMatch
+func (memory *Memory) GetMatch(id rose.RoomID) (*Match, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	match, ok := memory.matches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyMatch(match), nil
+}
+
+// GetMatchHistory implements Repository.GetMatchHistory
+func (memory *Memory) GetMatchHistory(userID rose.UserID, offset int, limit int) ([]*Match, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	// Collect all matches the user played in
+	history := make([]*Match, 0)
+	for _, match := range memory.matches {
+		for _, participant := range match.Participants {
+			if participant.UserID == userID {
+				history = append(history, match)
+				break
+			}
+		}
+	}
+
+	// Newest first
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].End.After(history[j].End)
+	})
+
+	// Page
+	if offset >= len(history) {
+		return []*Match{}, nil
+	}
+	history = history[offset:]
+	if limit < len(history) {
+		history = history[:limit]
+	}
+
+	page := make([]*Match, 0, len(history))
+	for _, match := range history {
+		page = append(page, copyMatch(match))
+	}
+	return page, nil
+}
+
+// SaveMatch implements Repository.SaveMatch
+func (memory *Memory) SaveMatch(match *Match) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	if _, ok := memory.matches[match.ID]; ok {
+		return ErrExists
+	}
+	memory.matches[match.ID] = copyMatch(match)
+	return nil
+}
+
+// GetBan implements Repository.GetBan
+func (memory *Memory) GetBan(userID rose.UserID) (*Ban, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	ban, ok := memory.bans[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &ban, nil
+}
+
+// SaveBan implements Repository.SaveBan
+func (memory *Memory) SaveBan(ban *Ban) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	memory.bans[ban.UserID] = *ban
+	return nil
+}
+
+// RemoveBan implements Repository.RemoveBan
+func (memory *Memory) RemoveBan(userID rose.UserID) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	delete(memory.bans, userID)
+	return nil
+}
+
+// GetLeaderboardScores implements Repository.GetLeaderboardScores
+func (memory *Memory) GetLeaderboardScores(board string, season string) ([]LeaderboardScore, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	scores := make([]LeaderboardScore, 0)
+	for key, score := range memory.scores {
+		if key.board == board && key.season == season {
+			scores = append(scores, score)
+		}
+	}
+	return scores, nil
+}
+
+// SaveLeaderboardScore implements Repository.SaveLeaderboardScore
+func (memory *Memory) SaveLeaderboardScore(score *LeaderboardScore) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	memory.scores[leaderboardKey{score.Board, score.Season, score.UserID}] = *score
+	return nil
+}
+
+// GetRating implements Repository.GetRating
+func (memory *Memory) GetRating(userID rose.UserID, mode string) (*Rating, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	rating, ok := memory.ratings[ratingKey{userID, mode}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rating, nil
+}
+
+// SaveRating implements Repository.SaveRating
+func (memory *Memory) SaveRating(rating *Rating, change *RatingChange) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	memory.ratings[ratingKey{rating.UserID, rating.Mode}] = *rating
+	if change != nil {
+		memory.changes = append(memory.changes, *change)
+	}
+	return nil
+}
+
+// GetRatingHistory implements Repository.GetRatingHistory
+func (memory *Memory) GetRatingHistory(userID rose.UserID, mode string, limit int) ([]RatingChange, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	// Changes are appended in order, walk back from the newest
+	history := make([]RatingChange, 0)
+	for i := len(memory.changes) - 1; i >= 0 && len(history) < limit; i-- {
+		change := memory.changes[i]
+		if change.UserID == userID && change.Mode == mode {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+// GetRelation implements Repository.GetRelation
+func (memory *Memory) GetRelation(userID rose.UserID, otherID rose.UserID) (*Relation, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	relation, ok := memory.relations[relationKey{userID, otherID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &relation, nil
+}
+
+// GetRelations implements Repository.GetRelations
+func (memory *Memory) GetRelations(userID rose.UserID) ([]Relation, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	relations := make([]Relation, 0)
+	for key, relation := range memory.relations {
+		if key.userID == userID {
+			relations = append(relations, relation)
+		}
+	}
+	return relations, nil
+}
+
+// GetIncomingRequests implements Repository.GetIncomingRequests
+func (memory *Memory) GetIncomingRequests(userID rose.UserID) ([]Relation, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	relations := make([]Relation, 0)
+	for key, relation := range memory.relations {
+		if key.otherID == userID && relation.State == RelationPending {
+			relations = append(relations, relation)
+		}
+	}
+	return relations, nil
+}
+
+// SaveRelation implements Repository.SaveRelation
+func (memory *Memory) SaveRelation(relation *Relation) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	memory.relations[relationKey{relation.UserID, relation.OtherID}] = *relation
+	return nil
+}
+
+// RemoveRelation implements Repository.RemoveRelation
+func (memory *Memory) RemoveRelation(userID rose.UserID, otherID rose.UserID) error {
+	memory.Lock()
+	defer memory.Unlock()
+
+	delete(memory.relations, relationKey{userID, otherID})
+	return nil
+}
+
+// Close implements Repository.Close
+func (memory *Memory) Close() error {
+	return nil
+}
+
+// copyMatch copy a match so callers can't alter what is stored
+func copyMatch(match *Match) *Match {
+	result := *match
+	result.Participants = append([]MatchParticipant(nil), match.Participants...)
+	return &result
+}
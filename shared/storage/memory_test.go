@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+)
+
+func TestMemoryAccount(t *testing.T) {
+	memory := NewMemory()
+
+	account := &Account{ID: 1, Name: "one"}
+	if err := memory.SaveAccount(account); err != nil {
+		t.Fatal(err)
+	}
+	account.Name = "changed"
+
+	stored, err := memory.GetAccount(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "one" {
+		t.Errorf("stored account changed with the caller's copy: %+v", stored)
+	}
+
+	if _, err := memory.GetAccount(2); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for an unknown account, got %v", err)
+	}
+}
+
+func TestMemorySaveMatchOnce(t *testing.T) {
+	memory := NewMemory()
+
+	match := &Match{
+		ID:   7,
+		Mode: "default",
+		Participants: []MatchParticipant{
+			{UserID: 1, Placement: 1},
+			{UserID: 2, Placement: 2},
+		},
+		End: time.Now(),
+	}
+	if err := memory.SaveMatch(match); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.SaveMatch(match); err != ErrExists {
+		t.Errorf("expected ErrExists saving the match twice, got %v", err)
+	}
+
+	history, err := memory.GetMatchHistory(2, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || len(history[0].Participants) != 2 {
+		t.Fatalf("expected one match with two participants, got %+v", history)
+	}
+
+	if history, _ := memory.GetMatchHistory(3, 0, 10); len(history) != 0 {
+		t.Errorf("expected no matches for a user that didn't play, got %d", len(history))
+	}
+}
+
+func TestMemoryMatchHistoryPages(t *testing.T) {
+	memory := NewMemory()
+
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		memory.SaveMatch(&Match{
+			ID:           rose.RoomID(i),
+			Participants: []MatchParticipant{{UserID: 1}},
+			End:          start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	history, err := memory.GetMatchHistory(1, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ID != 4 || history[1].ID != 3 {
+		t.Errorf("expected matches 4 and 3, newest first, got %+v", history)
+	}
+}
+
+func TestMemoryBan(t *testing.T) {
+	memory := NewMemory()
+
+	if _, err := memory.GetBan(1); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound without a ban, got %v", err)
+	}
+	if err := memory.SaveBan(&Ban{UserID: 1, Reason: "cheating"}); err != nil {
+		t.Fatal(err)
+	}
+	if ban, err := memory.GetBan(1); err != nil || ban.Reason != "cheating" {
+		t.Fatalf("expected the saved ban, got %+v %v", ban, err)
+	}
+	if err := memory.RemoveBan(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memory.GetBan(1); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after removing the ban, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// migrations every schema change ever made, in order. Never edit a released migration, add a new one instead.
+var migrations = []string{
+	// 1: Initial schema
+	`CREATE TABLE accounts (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		created INTEGER NOT NULL
+	);
+	CREATE TABLE profiles (
+		user_id INTEGER PRIMARY KEY,
+		display_name TEXT NOT NULL,
+		updated INTEGER NOT NULL
+	);
+	CREATE TABLE matches (
+		id INTEGER PRIMARY KEY,
+		mode TEXT NOT NULL,
+		region TEXT NOT NULL,
+		node_id INTEGER NOT NULL,
+		stats TEXT NOT NULL,
+		started INTEGER NOT NULL,
+		ended INTEGER NOT NULL
+	);
+	CREATE TABLE match_participants (
+		match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL,
+		team INTEGER NOT NULL,
+		placement INTEGER NOT NULL,
+		score INTEGER NOT NULL,
+		stats TEXT NOT NULL,
+		PRIMARY KEY (match_id, user_id)
+	);
+	CREATE INDEX match_participants_user ON match_participants(user_id);
+	CREATE TABLE bans (
+		user_id INTEGER PRIMARY KEY,
+		reason TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		expires INTEGER NOT NULL
+	);`,
+
+	// 2: Player profile details and stats
+	`ALTER TABLE profiles ADD COLUMN avatar_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE profiles ADD COLUMN level INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE profiles ADD COLUMN attributes TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE profiles ADD COLUMN games_played INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE profiles ADD COLUMN wins INTEGER NOT NULL DEFAULT 0;`,
+
+	// 3: Leaderboards
+	`CREATE TABLE leaderboard_scores (
+		board TEXT NOT NULL,
+		season TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		score INTEGER NOT NULL,
+		PRIMARY KEY (board, season, user_id)
+	);`,
+
+	// 4: Ratings
+	`CREATE TABLE ratings (
+		user_id INTEGER NOT NULL,
+		mode TEXT NOT NULL,
+		rating REAL NOT NULL,
+		deviation REAL NOT NULL,
+		volatility REAL NOT NULL,
+		games INTEGER NOT NULL,
+		updated INTEGER NOT NULL,
+		PRIMARY KEY (user_id, mode)
+	);
+	CREATE TABLE rating_history (
+		user_id INTEGER NOT NULL,
+		mode TEXT NOT NULL,
+		match_id INTEGER NOT NULL,
+		rating_before REAL NOT NULL,
+		rating_after REAL NOT NULL,
+		time INTEGER NOT NULL
+	);
+	CREATE INDEX rating_history_user ON rating_history(user_id, mode, time);`,
+
+	// 5: Friends
+	`CREATE TABLE relations (
+		user_id INTEGER NOT NULL,
+		other_id INTEGER NOT NULL,
+		state TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		PRIMARY KEY (user_id, other_id)
+	);
+	CREATE INDEX relations_other ON relations(other_id, state);`,
+}
+
+// migrate bring the database schema up to date
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`)
+	if err != nil {
+		return err
+	}
+
+	// Find out where we are
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	// Apply every missing migration in its own transaction
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Noticef("Migrated database to version %d", i+1)
+	}
+
+	return nil
+}
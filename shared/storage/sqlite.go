@@ -0,0 +1,464 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	// SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/op/go-logging"
+	"github.com/zeroZshadow/rose"
+)
+
+var log = logging.MustGetLogger("storage")
+
+// SQLite a Repository backed by an embedded SQLite database
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite open the SQLite database at the given DSN and run all pending migrations
+func OpenSQLite(dsn string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a single writer, don't make connections fight over it
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+// GetAccount implements Repository.GetAccount
+func (store *SQLite) GetAccount(id rose.UserID) (*Account, error) {
+	var created int64
+	account := &Account{ID: id}
+
+	err := store.db.QueryRow(`SELECT name, created FROM accounts WHERE id = ?`, int64(id)).Scan(&account.Name, &created)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	account.Created = fromUnixNano(created)
+	return account, nil
+}
+
+// SaveAccount implements Repository.SaveAccount
+func (store *SQLite) SaveAccount(account *Account) error {
+	_, err := store.db.Exec(`INSERT OR REPLACE INTO accounts (id, name, created) VALUES (?, ?, ?)`,
+		int64(account.ID), account.Name, toUnixNano(account.Created))
+	return err
+}
+
+// GetProfile implements Repository.GetProfile
+func (store *SQLite) GetProfile(userID rose.UserID) (*Profile, error) {
+	var updated int64
+	var attributes string
+	profile := &Profile{UserID: userID}
+
+	err := store.db.QueryRow(`SELECT display_name, avatar_id, level, attributes, games_played, wins, updated FROM profiles WHERE user_id = ?`, int64(userID)).
+		Scan(&profile.DisplayName, &profile.AvatarID, &profile.Level, &attributes, &profile.GamesPlayed, &profile.Wins, &updated)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Attributes = json.RawMessage(attributes)
+	profile.Updated = fromUnixNano(updated)
+	return profile, nil
+}
+
+// SaveProfile implements Repository.SaveProfile
+func (store *SQLite) SaveProfile(profile *Profile) error {
+	attributes := string(profile.Attributes)
+	if attributes == "" {
+		attributes = "{}"
+	}
+
+	_, err := store.db.Exec(`INSERT OR REPLACE INTO profiles (user_id, display_name, avatar_id, level, attributes, games_played, wins, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		int64(profile.UserID), profile.DisplayName, profile.AvatarID, profile.Level, attributes, profile.GamesPlayed, profile.Wins, toUnixNano(profile.Updated))
+	return err
+}
+
+// GetMatch implements Repository.GetMatch
+func (store *SQLite) GetMatch(id rose.RoomID) (*Match, error) {
+	row := store.db.QueryRow(`SELECT id, mode, region, node_id, stats, started, ended FROM matches WHERE id = ?`, int64(id))
+	match, err := scanMatch(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.loadParticipants(match); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// GetMatchHistory implements Repository.GetMatchHistory
+func (store *SQLite) GetMatchHistory(userID rose.UserID, offset int, limit int) ([]*Match, error) {
+	rows, err := store.db.Query(`SELECT m.id, m.mode, m.region, m.node_id, m.stats, m.started, m.ended
+		FROM matches m JOIN match_participants p ON p.match_id = m.id
+		WHERE p.user_id = ? ORDER BY m.ended DESC LIMIT ? OFFSET ?`, int64(userID), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]*Match, 0)
+	for rows.Next() {
+		match, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, match := range history {
+		if err := store.loadParticipants(match); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// SaveMatch implements Repository.SaveMatch
+func (store *SQLite) SaveMatch(match *Match) error {
+	stats, err := json.Marshal(match.Stats)
+	if err != nil {
+		return err
+	}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// A room plays a single match, reporting it again must not overwrite it
+	result, err := tx.Exec(`INSERT OR IGNORE INTO matches (id, mode, region, node_id, stats, started, ended) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		int64(match.ID), match.Mode, match.Region, int64(match.NodeID), string(stats), toUnixNano(match.Start), toUnixNano(match.End))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return ErrExists
+	}
+
+	for _, participant := range match.Participants {
+		participantStats, err := json.Marshal(participant.Stats)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO match_participants (match_id, user_id, team, placement, score, stats) VALUES (?, ?, ?, ?, ?, ?)`,
+			int64(match.ID), int64(participant.UserID), participant.Team, participant.Placement, participant.Score, string(participantStats))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBan implements Repository.GetBan
+func (store *SQLite) GetBan(userID rose.UserID) (*Ban, error) {
+	var created, expires int64
+	ban := &Ban{UserID: userID}
+
+	err := store.db.QueryRow(`SELECT reason, created, expires FROM bans WHERE user_id = ?`, int64(userID)).Scan(&ban.Reason, &created, &expires)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ban.Created = fromUnixNano(created)
+	ban.Expires = fromUnixNano(expires)
+	return ban, nil
+}
+
+// SaveBan implements Repository.SaveBan
+func (store *SQLite) SaveBan(ban *Ban) error {
+	_, err := store.db.Exec(`INSERT OR REPLACE INTO bans (user_id, reason, created, expires) VALUES (?, ?, ?, ?)`,
+		int64(ban.UserID), ban.Reason, toUnixNano(ban.Created), toUnixNano(ban.Expires))
+	return err
+}
+
+// RemoveBan implements Repository.RemoveBan
+func (store *SQLite) RemoveBan(userID rose.UserID) error {
+	_, err := store.db.Exec(`DELETE FROM bans WHERE user_id = ?`, int64(userID))
+	return err
+}
+
+// GetLeaderboardScores implements Repository.GetLeaderboardScores
+func (store *SQLite) GetLeaderboardScores(board string, season string) ([]LeaderboardScore, error) {
+	rows, err := store.db.Query(`SELECT user_id, score FROM leaderboard_scores WHERE board = ? AND season = ?`, board, season)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := make([]LeaderboardScore, 0)
+	for rows.Next() {
+		var userID int64
+		score := LeaderboardScore{Board: board, Season: season}
+
+		if err := rows.Scan(&userID, &score.Score); err != nil {
+			return nil, err
+		}
+
+		score.UserID = rose.UserID(userID)
+		scores = append(scores, score)
+	}
+
+	return scores, rows.Err()
+}
+
+// SaveLeaderboardScore implements Repository.SaveLeaderboardScore
+func (store *SQLite) SaveLeaderboardScore(score *LeaderboardScore) error {
+	_, err := store.db.Exec(`INSERT OR REPLACE INTO leaderboard_scores (board, season, user_id, score) VALUES (?, ?, ?, ?)`,
+		score.Board, score.Season, int64(score.UserID), score.Score)
+	return err
+}
+
+// GetRating implements Repository.GetRating
+func (store *SQLite) GetRating(userID rose.UserID, mode string) (*Rating, error) {
+	var updated int64
+	rating := &Rating{UserID: userID, Mode: mode}
+
+	err := store.db.QueryRow(`SELECT rating, deviation, volatility, games, updated FROM ratings WHERE user_id = ? AND mode = ?`, int64(userID), mode).
+		Scan(&rating.Rating, &rating.Deviation, &rating.Volatility, &rating.Games, &updated)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rating.Updated = fromUnixNano(updated)
+	return rating, nil
+}
+
+// SaveRating implements Repository.SaveRating
+func (store *SQLite) SaveRating(rating *Rating, change *RatingChange) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO ratings (user_id, mode, rating, deviation, volatility, games, updated) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		int64(rating.UserID), rating.Mode, rating.Rating, rating.Deviation, rating.Volatility, rating.Games, toUnixNano(rating.Updated))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if change != nil {
+		_, err = tx.Exec(`INSERT INTO rating_history (user_id, mode, match_id, rating_before, rating_after, time) VALUES (?, ?, ?, ?, ?, ?)`,
+			int64(change.UserID), change.Mode, int64(change.MatchID), change.Before, change.After, toUnixNano(change.Time))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRatingHistory implements Repository.GetRatingHistory
+func (store *SQLite) GetRatingHistory(userID rose.UserID, mode string, limit int) ([]RatingChange, error) {
+	rows, err := store.db.Query(`SELECT match_id, rating_before, rating_after, time FROM rating_history WHERE user_id = ? AND mode = ? ORDER BY time DESC LIMIT ?`,
+		int64(userID), mode, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]RatingChange, 0)
+	for rows.Next() {
+		var matchID, changed int64
+		change := RatingChange{UserID: userID, Mode: mode}
+
+		if err := rows.Scan(&matchID, &change.Before, &change.After, &changed); err != nil {
+			return nil, err
+		}
+
+		change.MatchID = rose.RoomID(matchID)
+		change.Time = fromUnixNano(changed)
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+// GetRelation implements Repository.GetRelation
+func (store *SQLite) GetRelation(userID rose.UserID, otherID rose.UserID) (*Relation, error) {
+	var state string
+	var created int64
+	relation := &Relation{UserID: userID, OtherID: otherID}
+
+	err := store.db.QueryRow(`SELECT state, created FROM relations WHERE user_id = ? AND other_id = ?`, int64(userID), int64(otherID)).Scan(&state, &created)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	relation.State = RelationState(state)
+	relation.Created = fromUnixNano(created)
+	return relation, nil
+}
+
+// GetRelations implements Repository.GetRelations
+func (store *SQLite) GetRelations(userID rose.UserID) ([]Relation, error) {
+	return store.queryRelations(`SELECT user_id, other_id, state, created FROM relations WHERE user_id = ?`, int64(userID))
+}
+
+// GetIncomingRequests implements Repository.GetIncomingRequests
+func (store *SQLite) GetIncomingRequests(userID rose.UserID) ([]Relation, error) {
+	return store.queryRelations(`SELECT user_id, other_id, state, created FROM relations WHERE other_id = ? AND state = ?`, int64(userID), string(RelationPending))
+}
+
+func (store *SQLite) queryRelations(query string, args ...interface{}) ([]Relation, error) {
+	rows, err := store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := make([]Relation, 0)
+	for rows.Next() {
+		var userID, otherID, created int64
+		var state string
+
+		if err := rows.Scan(&userID, &otherID, &state, &created); err != nil {
+			return nil, err
+		}
+
+		relations = append(relations, Relation{
+			UserID:  rose.UserID(userID),
+			OtherID: rose.UserID(otherID),
+			State:   RelationState(state),
+			Created: fromUnixNano(created),
+		})
+	}
+
+	return relations, rows.Err()
+}
+
+// SaveRelation implements Repository.SaveRelation
+func (store *SQLite) SaveRelation(relation *Relation) error {
+	_, err := store.db.Exec(`INSERT OR REPLACE INTO relations (user_id, other_id, state, created) VALUES (?, ?, ?, ?)`,
+		int64(relation.UserID), int64(relation.OtherID), string(relation.State), toUnixNano(relation.Created))
+	return err
+}
+
+// RemoveRelation implements Repository.RemoveRelation
+func (store *SQLite) RemoveRelation(userID rose.UserID, otherID rose.UserID) error {
+	_, err := store.db.Exec(`DELETE FROM relations WHERE user_id = ? AND other_id = ?`, int64(userID), int64(otherID))
+	return err
+}
+
+// Close implements Repository.Close
+func (store *SQLite) Close() error {
+	return store.db.Close()
+}
+
+// scanner is implemented by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMatch(row scanner) (*Match, error) {
+	var id, nodeID, started, ended int64
+	var stats string
+	match := &Match{}
+
+	err := row.Scan(&id, &match.Mode, &match.Region, &nodeID, &stats, &started, &ended)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(stats), &match.Stats); err != nil {
+		return nil, err
+	}
+
+	match.ID = rose.RoomID(id)
+	match.NodeID = rose.UserID(nodeID)
+	match.Start = fromUnixNano(started)
+	match.End = fromUnixNano(ended)
+	return match, nil
+}
+
+func (store *SQLite) loadParticipants(match *Match) error {
+	rows, err := store.db.Query(`SELECT user_id, team, placement, score, stats FROM match_participants WHERE match_id = ? ORDER BY placement`, int64(match.ID))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	match.Participants = make([]MatchParticipant, 0)
+	for rows.Next() {
+		var userID int64
+		var stats string
+		participant := MatchParticipant{}
+
+		if err := rows.Scan(&userID, &participant.Team, &participant.Placement, &participant.Score, &stats); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(stats), &participant.Stats); err != nil {
+			return err
+		}
+
+		participant.UserID = rose.UserID(userID)
+		match.Participants = append(match.Participants, participant)
+	}
+
+	return rows.Err()
+}
+
+// toUnixNano store times as nanoseconds, keeping the zero time as 0
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano).UTC()
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/zeroZshadow/rose"
+)
+
+// ErrNotFound returned when the requested record does not exist
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrExists returned when a record that can only be written once already exists
+var ErrExists = errors.New("storage: already exists")
+
+// Instance the repository opened at startup
+var Instance Repository
+
+// Account a known user
+type Account struct {
+	ID      rose.UserID
+	Name    string
+	Created time.Time
+}
+
+// Profile public information and lifetime stats of a player
+type Profile struct {
+	UserID      rose.UserID
+	DisplayName string
+	AvatarID    int
+	Level       int
+	Attributes  json.RawMessage
+	GamesPlayed int
+	Wins        int
+	Updated     time.Time
+}
+
+// MatchParticipant the outcome of a match for a single player
+type MatchParticipant struct {
+	UserID    rose.UserID
+	Team      int
+	Placement int
+	Score     int64
+	Stats     map[string]string
+}
+
+// Match a finished match, identified by the id of the room it was played in
+type Match struct {
+	ID           rose.RoomID
+	Mode         string
+	Region       string
+	NodeID       rose.UserID
+	Participants []MatchParticipant
+	Stats        map[string]string
+	Start        time.Time
+	End          time.Time
+}
+
+// Ban a user that is not allowed to play, a zero Expires is permanent
+type Ban struct {
+	UserID  rose.UserID
+	Reason  string
+	Created time.Time
+	Expires time.Time
+}
+
+// Active returns true if the ban still applies at the given time
+func (ban *Ban) Active(now time.Time) bool {
+	return ban.Expires.IsZero() || now.Before(ban.Expires)
+}
+
+// LeaderboardScore the score of a user on a leaderboard during a season
+type LeaderboardScore struct {
+	Board  string
+	Season string
+	UserID rose.UserID
+	Score  int64
+}
+
+// Rating the skill rating of a user in a game mode
+type Rating struct {
+	UserID     rose.UserID
+	Mode       string
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	Games      int
+	Updated    time.Time
+}
+
+// RatingChange a change of a user's rating caused by a match
+type RatingChange struct {
+	UserID  rose.UserID
+	Mode    string
+	MatchID rose.RoomID
+	Before  float64
+	After   float64
+	Time    time.Time
+}
+
+// RelationState the state of a relation between two users
+type RelationState string
+
+// Relation states
+const (
+	// RelationPending UserID asked OtherID to be friends
+	RelationPending RelationState = "pending"
+	// RelationFriends UserID and OtherID are friends, stored in both directions
+	RelationFriends RelationState = "friends"
+	// RelationBlocked UserID blocked OtherID
+	RelationBlocked RelationState = "blocked"
+)
+
+// Relation the relation of one user towards another
+type Relation struct {
+	UserID  rose.UserID
+	OtherID rose.UserID
+	State   RelationState
+	Created time.Time
+}
+
+// Repository persistent storage for accounts, profiles, match history, bans, leaderboards, ratings and friends
+type Repository interface {
+	GetAccount(id rose.UserID) (*Account, error)
+	SaveAccount(account *Account) error
+
+	GetProfile(userID rose.UserID) (*Profile, error)
+	SaveProfile(profile *Profile) error
+
+	GetMatch(id rose.RoomID) (*Match, error)
+	GetMatchHistory(userID rose.UserID, offset int, limit int) ([]*Match, error)
+	SaveMatch(match *Match) error
+
+	GetBan(userID rose.UserID) (*Ban, error)
+	SaveBan(ban *Ban) error
+	RemoveBan(userID rose.UserID) error
+
+	GetLeaderboardScores(board string, season string) ([]LeaderboardScore, error)
+	SaveLeaderboardScore(score *LeaderboardScore) error
+
+	GetRating(userID rose.UserID, mode string) (*Rating, error)
+	SaveRating(rating *Rating, change *RatingChange) error
+	GetRatingHistory(userID rose.UserID, mode string, limit int) ([]RatingChange, error)
+
+	GetRelation(userID rose.UserID, otherID rose.UserID) (*Relation, error)
+	GetRelations(userID rose.UserID) ([]Relation, error)
+	GetIncomingRequests(userID rose.UserID) ([]Relation, error)
+	SaveRelation(relation *Relation) error
+	RemoveRelation(userID rose.UserID, otherID rose.UserID) error
+
+	Close() error
+}
+
+// Open open the repository for the given DSN and bring its schema up to date.
+// "memory" selects the in-memory backend, anything else is passed to SQLite.
+func Open(dsn string) (Repository, error) {
+	if dsn == "memory" {
+		return NewMemory(), nil
+	}
+
+	return OpenSQLite(dsn)
+}
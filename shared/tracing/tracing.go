@@ -0,0 +1,114 @@
+// Package tracing sets up OpenTelemetry tracing for the servers and carries trace context
+// between them. The context travels as a W3C traceparent string, in the room token from
+// the master to the node and in the messages the node sends back to the master.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceParentKey the key the W3C propagator uses for the trace context
+const traceParentKey = "traceparent"
+
+// Options how and where to export spans
+type Options struct {
+	Service     string  // Name the spans are reported under
+	Exporter    string  // "" disables tracing, "stdout" or "file"
+	File        string  // Where the file exporter writes
+	SampleRatio float64 // Share of new traces to keep, traces started elsewhere follow the sender
+}
+
+var (
+	provider   *sdktrace.TracerProvider
+	output     io.Closer
+	propagator = propagation.TraceContext{}
+)
+
+// Setup start exporting spans, without an exporter spans are created but never recorded
+func Setup(options Options) error {
+	var writer io.Writer
+	switch options.Exporter {
+	case "":
+		return nil
+	case "stdout":
+		writer = os.Stdout
+	case "file":
+		file, err := os.OpenFile(options.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		writer = file
+		output = file
+	default:
+		return fmt.Errorf("unknown trace exporter %q", options.Exporter)
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(writer))
+	if err != nil {
+		return err
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", options.Service))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return nil
+}
+
+// Shutdown flush the spans that haven't been exported yet, waiting at most timeout
+func Shutdown(timeout time.Duration) error {
+	if provider == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := provider.Shutdown(ctx)
+	if output != nil {
+		output.Close()
+	}
+	return err
+}
+
+// Tracer the tracer for a package
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Inject the trace context of ctx as a traceparent string, empty if there is no trace
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier[traceParentKey]
+}
+
+// Extract a context continuing the trace of the traceparent string, an empty or broken string starts nothing
+func Extract(traceParent string) context.Context {
+	if traceParent == "" {
+		return context.Background()
+	}
+	return propagator.Extract(context.Background(), propagation.MapCarrier{traceParentKey: traceParent})
+}
+
+// Fail mark the span as failed
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}